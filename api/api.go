@@ -38,6 +38,16 @@ func errInvalidRequest(err error) render.Renderer {
 	}
 }
 
+// errUpstream produces a chi/render object representing a failed lookup on a server the request depends on
+func errUpstream(err error) render.Renderer {
+	return &errResponse{
+		Err:            err,
+		HTTPStatusCode: 502,
+		StatusText:     "Upstream lookup failed.",
+		ErrorText:      err.Error(),
+	}
+}
+
 // errRender produces a chi/render object representing an error whilst rendering
 func errRender(err error) render.Renderer {
 	return &errResponse{
@@ -1,12 +1,15 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
 	"github.com/tomtom5152/dnsyo/dnsyo"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -17,12 +20,15 @@ const (
 
 func (api *Server) queryHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
-	q := &dnsyo.Query{
-		Domain: chi.URLParam(r, "domain"),
-	}
+	q := &dnsyo.Query{}
 	var sl dnsyo.ServerList
 	sl = api.Servers
 
+	if err = q.SetDomain(chi.URLParam(r, "domain")); err != nil {
+		render.Render(w, r, errInvalidRequest(err))
+		return
+	}
+
 	// check if the user has specified a query type
 	var recordType = "A"
 	if t := r.FormValue("t"); t != "" {
@@ -32,8 +38,89 @@ func (api *Server) queryHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	if err = q.SetType(recordType); err != nil {
 		render.Render(w, r, errInvalidRequest(err))
+		return
+	}
+
+	// check if the user has specified a class
+	if c := r.FormValue("class"); c != "" {
+		if err = q.SetClass(c); err != nil {
+			render.Render(w, r, errInvalidRequest(err))
+			return
+		}
+	}
+
+	// check if the domain is an IP address to look up the PTR record of
+	var reverse = r.FormValue("x")
+	if reverse == "" {
+		reverse = r.FormValue("reverse")
+	}
+	if reverse != "" {
+		x, err := strconv.ParseBool(reverse)
+		if err != nil {
+			render.Render(w, r, errInvalidRequest(err))
+			return
+		}
+		if x {
+			if r.FormValue("t") != "" || r.FormValue("type") != "" {
+				render.Render(w, r, errInvalidRequest(errors.New("x always looks up PTR records and cannot be used with a type")))
+				return
+			}
+			if err = q.SetReverse(q.Domain); err != nil {
+				render.Render(w, r, errInvalidRequest(err))
+				return
+			}
+		}
+	}
+
+	// check if the results should be compared to the authoritative answer
+	if a := r.FormValue("authoritative"); a != "" {
+		baseline, err := strconv.ParseBool(a)
+		if err != nil {
+			render.Render(w, r, errInvalidRequest(err))
+			return
+		}
+		if baseline {
+			if _, err = q.ExpectAuthoritative(""); err != nil {
+				render.Render(w, r, errUpstream(err))
+				return
+			}
+		}
+	}
+
+	// check if DNSSEC should be checked
+	if d := r.FormValue("dnssec"); d != "" {
+		if q.DNSSEC, err = strconv.ParseBool(d); err != nil {
+			render.Render(w, r, errInvalidRequest(err))
+			return
+		}
+	}
+
+	// check if servers should identify the instance that answered
+	if n := r.FormValue("nsid"); n != "" {
+		if q.NSID, err = strconv.ParseBool(n); err != nil {
+			render.Render(w, r, errInvalidRequest(err))
+			return
+		}
 	}
 
+	// check if we have any groups or tags specified, apply the result
+	if groups := formValues(r, "g", "group"); len(groups) > 0 {
+		sl, err = sl.FilterGroups(groups)
+		if err != nil {
+			render.Render(w, r, errInvalidRequest(err))
+			return
+		}
+	}
+	if tags := formValues(r, "tag"); len(tags) > 0 {
+		sl, err = sl.FilterTags(tags)
+		if err != nil {
+			render.Render(w, r, errInvalidRequest(err))
+			return
+		}
+	}
+
+	sl = sl.Enabled()
+
 	// check if we have a country specified, apply the result
 	var country string
 	if c := r.FormValue("c"); c != "" {
@@ -49,6 +136,15 @@ func (api *Server) queryHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// check if we have software specified, apply the result
+	if software := r.FormValue("software"); software != "" {
+		sl, err = sl.FilterSoftware(software)
+		if err != nil {
+			render.Render(w, r, errInvalidRequest(err))
+			return
+		}
+	}
+
 	// check if we have a number of servers specified, bound and apply the result
 	numServers := 0
 	if n, _ := strconv.Atoi(r.FormValue("q")); n != 0 {
@@ -75,8 +171,79 @@ func (api *Server) queryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// check if we have a maximum response time, parsed before querying so it can be rejected early
+	var maxRTT time.Duration
+	if m := r.FormValue("max_rtt"); m != "" {
+		maxRTT, err = time.ParseDuration(m)
+		if err != nil {
+			render.Render(w, r, errInvalidRequest(err))
+			return
+		}
+	}
+
+	// check if the results should be wrapped with the domain in both its ASCII and Unicode forms
+	var envelope bool
+	if e := r.FormValue("envelope"); e != "" {
+		if envelope, err = strconv.ParseBool(e); err != nil {
+			render.Render(w, r, errInvalidRequest(err))
+			return
+		}
+	}
+
+	// repeat the query for each client subnet and return the results for each
+	if prefixes := formValues(r, "ecs"); len(prefixes) > 0 {
+		m, err := sl.ExecuteECSQuery(q, prefixes, apiQueryThreads)
+		if err != nil {
+			render.Render(w, r, errInvalidRequest(err))
+			return
+		}
+		if envelope {
+			renderJSON(w, r, m.ToJSON)
+		} else {
+			render.JSON(w, r, m.Results)
+		}
+		return
+	}
+
 	q.Results = sl.ExecuteQuery(q, apiQueryThreads)
+	if maxRTT > 0 {
+		q.Results = q.Results.FilterRTT(maxRTT)
+	}
+	if q.Expected != "" {
+		q.Classify()
+	}
 
-	render.JSON(w, r, q.Results)
+	if envelope {
+		renderJSON(w, r, q.ToJSON)
+	} else {
+		render.JSON(w, r, q.Results)
+	}
+	return
+}
+
+// renderJSON writes the output of one of the ToJSON functions for ?envelope=true, which include the domain in both
+// its ASCII and Unicode forms alongside the results.
+func renderJSON(w http.ResponseWriter, r *http.Request, toJSON func() (string, error)) {
+	text, err := toJSON()
+	if err != nil {
+		render.Render(w, r, errRender(err))
+		return
+	}
+	render.JSON(w, r, json.RawMessage(text))
+}
+
+// formValues collects the values of a repeatable query string parameter under any of its names.
+// Comma separated values are split so that both ?g=a&g=b and ?g=a,b may be used.
+func formValues(r *http.Request, names ...string) (values []string) {
+	r.ParseForm()
+	for _, name := range names {
+		for _, v := range r.Form[name] {
+			for _, part := range strings.Split(v, ",") {
+				if part != "" {
+					values = append(values, part)
+				}
+			}
+		}
+	}
 	return
 }
@@ -41,14 +41,24 @@ func TestAPIServer_QueryHandler(t *testing.T) {
 		So(json, ShouldEndWith, "}\n")
 
 		Convey("check the postec fail is in there", func() {
-			So(json, ShouldContainSubstring, `"!postec.nottingham.ac.uk":{"Answer":"","Error":"TIMEOUT"}`)
+			So(json, ShouldContainSubstring, `"!postec.nottingham.ac.uk":{"Answer":"","Error":"TIMEOUT","ErrorCode":"timeout","Country":"GB"}`)
 		})
 
 		Convey("check the google result is sensible", func() {
-			So(json, ShouldContainSubstring, `"google-public-dns-a.google.com":{"Answer":"93.184.216.34"}`)
+			So(json, ShouldContainSubstring, `"google-public-dns-a.google.com":{"Answer":"93.184.216.34","RTT":`)
 		})
 	})
 
+	Convey("internationalised names are returned in both forms with an envelope", t, func() {
+		resp, err := http.Get(server.URL + "/v1/query/bücher.example?q=1&envelope=true")
+		So(err, ShouldBeNil)
+		So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		So(string(data), ShouldContainSubstring, `"Domain":"xn--bcher-kva.example","IDN":"bücher.example"`)
+	})
+
 	Convey("check query string params", t, func() {
 		Convey("number of servers", func() {
 			Convey("short form", func() {
@@ -104,6 +114,34 @@ func TestAPIServer_QueryHandler(t *testing.T) {
 			})
 		})
 
+		Convey("group", func() {
+			Convey("short form", func() {
+				resp, err := http.Get(testURL + "?g=opendns")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+				data, _ := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				json := string(data)
+
+				So(strings.Count(json, "Answer"), ShouldEqual, 2)
+				So(json, ShouldContainSubstring, "resolver1.opendns.com")
+			})
+
+			Convey("long form with a country", func() {
+				resp, err := http.Get(testURL + "?group=google,quad9&country=US")
+				So(err, ShouldBeNil)
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+				data, _ := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				json := string(data)
+
+				So(strings.Count(json, "Answer"), ShouldEqual, 2)
+				So(json, ShouldContainSubstring, "google-public-dns-a.google.com")
+			})
+		})
+
 		Convey("type", func() {
 			Convey("short form", func() {
 				resp, err := http.Get(server.URL + "/v1/query/exmaple.com?t=MX") // deliberate typo so we get a result
@@ -155,5 +193,65 @@ func TestAPIServer_QueryHandler(t *testing.T) {
 			So(err, ShouldBeNil)
 			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
 		})
+
+		Convey("invalid authoritative", func() {
+			resp, err := http.Get(testURL + "?authoritative=maybe")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("invalid class", func() {
+			resp, err := http.Get(testURL + "?class=XX")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("reverse lookup of a name", func() {
+			resp, err := http.Get(testURL + "?x=true")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("reverse lookup with a type", func() {
+			resp, err := http.Get(server.URL + "/v1/query/192.0.2.1?x=true&t=A")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("invalid dnssec", func() {
+			resp, err := http.Get(testURL + "?dnssec=maybe")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("invalid ecs", func() {
+			resp, err := http.Get(testURL + "?ecs=192.0.2.0/99")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("unknown software", func() {
+			resp, err := http.Get(testURL + "?software=nothing")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("invalid max_rtt", func() {
+			resp, err := http.Get(testURL + "?max_rtt=fast")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("empty group", func() {
+			resp, err := http.Get(testURL + "?group=nobody")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("unknown tag", func() {
+			resp, err := http.Get(testURL + "?tag=nobody")
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
 	})
 }
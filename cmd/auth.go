@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/tomtom5152/dnsyo/dnsyo"
+	"os"
+)
+
+var (
+	authType     string
+	authResolver string
+	authFormat   string
+)
+
+// authCmd represents the auth command
+var authCmd = &cobra.Command{
+	Use:   "auth <domain>",
+	Short: "Check the authoritative nameservers for a domain agree",
+	Long: `Finds the zone containing the domain and queries every address of each of its authoritative nameservers
+directly, comparing the SOA serials and answers they give.
+
+The delegation is followed from the root servers unless --resolver is given. Lame delegations and disagreements are
+reported, and the command exits with a non-zero status if any are found.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		q := &dnsyo.Query{}
+		if err := q.SetDomain(args[0]); err != nil {
+			log.Fatal(err.Error())
+		}
+		if err := q.SetType(authType); err != nil {
+			log.Fatal(err.Error())
+		}
+
+		zone, sl, err := dnsyo.FindNameservers(q.Domain, authResolver)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		log.Debugf("found %d nameserver addresses for %s", len(sl), zone)
+
+		ac := sl.CheckAuthoritative(zone, q)
+
+		switch authFormat {
+		case "json":
+			text, err := ac.ToJSON()
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+			fmt.Println(text)
+
+		case "text":
+			print(ac.ToTextSummary())
+
+		default:
+			log.Fatalf("unknown format %s", authFormat)
+		}
+
+		if len(ac.Problems()) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+
+	authCmd.Flags().StringVar(&authType, "type", "A", "Type of query to perform")
+	authCmd.Flags().StringVar(&authResolver, "resolver", "", "Find the nameservers through this resolver instead of from the root")
+	authCmd.Flags().StringVar(&authFormat, "format", "text", "Output format (text, json)")
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/tomtom5152/dnsyo/dnsyo"
+	"os"
+)
+
+var (
+	certIssuer   string
+	certWildcard bool
+	certPort     int
+	certProto    string
+	certFormat   string
+)
+
+// certCmd represents the cert command
+var certCmd = &cobra.Command{
+	Use:   "cert <domain>",
+	Short: "Check the CAA and TLSA records resolvers see before issuing a certificate",
+	Long: `Finds the CAA records that apply to the domain from each server, climbing towards the root as a CA does, and
+reports whether they allow the --ca to issue a certificate. A domain starting with *. is checked for a wildcard
+certificate. With --tlsa-port the TLSA records of the service are queried and checked as well.
+
+The command exits with a non-zero status if any server's view does not allow the CA to issue.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if certIssuer == "" {
+			log.Fatal("--ca is required, e.g. --ca letsencrypt.org")
+		}
+
+		c, err := dnsyo.NewCertCheck(args[0], certIssuer, certWildcard, certPort, certProto)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		sl := selectServers(cmd)
+		sl.ExecuteCertCheck(c, numThreads)
+
+		switch certFormat {
+		case "json":
+			text, err := c.ToJSON()
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+			fmt.Println(text)
+
+		case "text":
+			print(c.ToTextSummary())
+
+		default:
+			log.Fatalf("unknown format %s", certFormat)
+		}
+
+		if c.Forbidden() > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(certCmd)
+
+	certCmd.Flags().StringVar(&certIssuer, "ca", "", "Identifying domain of the CA, as used in CAA records")
+	certCmd.Flags().BoolVar(&certWildcard, "wildcard", false, "Check for a wildcard certificate")
+	certCmd.Flags().IntVar(&certPort, "tlsa-port", 0, "Also check the TLSA records of the service on this port")
+	certCmd.Flags().StringVar(&certProto, "tlsa-proto", "tcp", "Protocol of the service for the TLSA records")
+	certCmd.Flags().StringVar(&certFormat, "format", "text", "Output format (text, json)")
+
+	addServerFlags(certCmd)
+}
@@ -15,8 +15,11 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -28,53 +31,254 @@ var (
 	resolverfile string
 	country      string
 	requestType  string
+	requestTypes []string
+	requestClass string
+	queryFile    string
+	reverse      bool
 	numThreads   int
+	groups       []string
+	tags         []string
+	maxQueryRTT  time.Duration
+	showLatency  bool
+	expect       []string
+	queryFormat  string
+	authBaseline bool
+	checkDNSSEC  bool
+	validate     bool
+	trustAnchor  string
+	validateWith string
+	ecsPrefixes  []string
+	requestNSID  bool
+	queryChaos   bool
+	software     string
+	groupBy      []string
 )
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "dnsyo <domain>",
+	Use:   "dnsyo <domain>...",
 	Short: "Compare the DNS results of 1000+ DNS servers",
 	Long:  `Basically dig, if dig queried over 1000 servers and collated their results.`,
-	Args:  cobra.MinimumNArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && queryFile == "" {
+			return errors.New("requires at least one domain, or --file")
+		}
+		return nil
+	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
 		// perform a lookup
 		q := &dnsyo.Query{
-			Domain: args[0],
+			Expected: strings.Join(expect, "\n"),
+			DNSSEC:   checkDNSSEC,
+			NSID:     requestNSID,
+			Chaos:    queryChaos,
 		}
 		err := q.SetType(requestType)
 		if err != nil {
 			log.Fatal(err.Error())
 		}
-
-		sl, err := dnsyo.ServersFromFile(resolverfile)
-		if err != nil {
+		if cmd.Flags().Changed("class") {
+			if err := q.SetClass(requestClass); err != nil {
+				log.Fatal(err.Error())
+			}
+		}
+		if err := q.SetGroupBy(groupBy); err != nil {
 			log.Fatal(err.Error())
-			return
 		}
 
-		if country != "" {
-			sl, err = sl.FilterCountry(country)
+		if validate {
+			q.Validator = dnsyo.NewValidator(validateWith)
+			if trustAnchor != "" {
+				if err := q.Validator.LoadTrustAnchors(trustAnchor); err != nil {
+					log.Fatal(err.Error())
+				}
+			}
+		}
+
+		// make a query for every domain and type, and each line of the file
+		types := requestTypes
+		if len(types) == 0 {
+			types = []string{requestType}
+		}
+		b := &dnsyo.Batch{}
+		if reverse {
+			if len(requestTypes) > 0 || cmd.Flags().Changed("type") {
+				log.Fatal("-x always looks up PTR records and cannot be used with --type or --types")
+			}
+			for _, ip := range args {
+				if err := b.AddReverse(*q, ip); err != nil {
+					log.Fatal(err.Error())
+				}
+			}
+		} else {
+			b, err = dnsyo.NewBatch(*q, args, types)
 			if err != nil {
 				log.Fatal(err.Error())
 			}
 		}
+		if queryFile != "" {
+			if err := b.ReadQueriesFile(queryFile, *q); err != nil {
+				log.Fatal(err.Error())
+			}
+		}
+		if len(b.Queries) == 0 {
+			log.Fatal("no queries to make")
+		}
+		if len(b.Queries) > 1 && len(expect) > 0 {
+			log.Fatal("--expect can only be used with a single query")
+		}
+
+		if authBaseline {
+			if len(expect) > 0 {
+				log.Fatal("--authoritative and --expect cannot be used together")
+			}
+			for _, bq := range b.Queries {
+				ac, err := bq.ExpectAuthoritative("")
+				if err != nil {
+					log.Fatal(err.Error())
+				}
+				for _, p := range ac.Problems() {
+					log.Warn(p)
+				}
+			}
+		}
+
+		sl := selectServers(cmd)
 
-		if servers != 0 {
-			sl, err = sl.NRandom(servers)
+		if len(ecsPrefixes) > 0 {
+			if len(b.Queries) > 1 {
+				log.Fatal("--ecs can only be used with a single query")
+			}
+			m, err := sl.ExecuteECSQuery(b.Queries[0], ecsPrefixes, numThreads)
 			if err != nil {
 				log.Fatal(err.Error())
 			}
+
+			switch queryFormat {
+			case "json":
+				text, err := m.ToJSON()
+				if err != nil {
+					log.Fatal(err.Error())
+				}
+				fmt.Println(text)
+
+			case "text":
+				print(m.ToTextSummary())
+
+			default:
+				log.Fatalf("unknown format %s", queryFormat)
+			}
+			return
+		}
+
+		// all of the queries share one pool of threads
+		sl.ExecuteBatch(b, numThreads)
+		for _, bq := range b.Queries {
+			if maxQueryRTT > 0 {
+				bq.Results = bq.Results.FilterRTT(maxQueryRTT)
+			}
+			if bq.Expected != "" {
+				bq.Classify()
+			}
 		}
 
-		q.Results = sl.ExecuteQuery(q, numThreads)
+		switch queryFormat {
+		case "json":
+			var text string
+			if len(b.Queries) == 1 {
+				text, err = b.Queries[0].ToJSON()
+			} else {
+				text, err = b.ToJSON()
+			}
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+			fmt.Println(text)
+
+		case "text":
+			if len(b.Queries) == 1 {
+				print(b.Queries[0].ToTextSummary())
+			} else {
+				print(b.ToTextSummary())
+			}
+			for _, bq := range b.Queries {
+				if showLatency {
+					print(bq.ToLatencyTable())
+				}
+			}
 
-		print(q.ToTextSummary())
+		default:
+			log.Fatalf("unknown format %s", queryFormat)
+		}
 	},
 }
 
+// addServerFlags adds the flags used by selectServers to choose the servers to query to a command.
+func addServerFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&servers, "servers", "q", 500, "Number of servers to query (0=ALL)")
+	cmd.Flags().StringVarP(&country, "country", "c", "", "Query servers by two letter country code")
+	cmd.Flags().StringVar(&software, "software", "", "Query servers running software containing this name")
+	cmd.Flags().StringSliceVarP(&groups, "group", "g", nil,
+		"Query servers in a group, either a tag or one of "+strings.Join(dnsyo.GroupNames(), ", "))
+	cmd.Flags().StringSliceVar(&tags, "tag", nil, "Query servers with a tag")
+}
+
+// selectServers loads the resolver file and picks the servers to query using the flags shared by the commands that
+// query servers: --group, --tag, --country, --software and --servers.
+func selectServers(cmd *cobra.Command) dnsyo.ServerList {
+	sl, err := dnsyo.ServersFromFile(resolverfile)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if len(groups) > 0 {
+		sl, err = sl.FilterGroups(groups)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	if len(tags) > 0 {
+		sl, err = sl.FilterTags(tags)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	// disabled servers are removed after groups are applied so built in servers can't bring them back
+	sl = sl.Enabled()
+
+	if country != "" {
+		sl, err = sl.FilterCountry(country)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	if software != "" {
+		sl, err = sl.FilterSoftware(software)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	// query the whole of a group unless a number of servers was asked for
+	if (len(groups) > 0 || len(tags) > 0) && !cmd.Flags().Changed("servers") && len(sl) < servers {
+		servers = 0
+	}
+
+	if servers != 0 {
+		sl, err = sl.NRandom(servers)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	return sl
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -98,7 +302,33 @@ func init() {
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	//rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-	rootCmd.Flags().IntVarP(&servers, "servers", "q", 500, "Number of servers to query (0=ALL)")
-	rootCmd.Flags().StringVarP(&country, "country", "c", "", "Query servers by two letter country code")
+	addServerFlags(rootCmd)
 	rootCmd.Flags().StringVarP(&requestType, "type", "", "A", "Type of query to perform")
+	rootCmd.Flags().StringVar(&requestClass, "class", "IN", "Class of the records to query, e.g. IN, CH or HS")
+	rootCmd.Flags().StringSliceVar(&requestTypes, "types", nil, "Query each domain for several types, e.g. A,AAAA,MX")
+	rootCmd.Flags().BoolVarP(&reverse, "reverse", "x", false,
+		"Look up the PTR records of IP addresses given instead of domains")
+	rootCmd.Flags().StringVar(&queryFile, "file", "", `Also query the names in a file with one "name [type]" per line`)
+	rootCmd.Flags().DurationVar(&maxQueryRTT, "max-rtt", 0, "Ignore servers that take longer than this to respond (0=no limit)")
+	rootCmd.Flags().BoolVar(&showLatency, "latency", false, "List the response time of each server, fastest first")
+	rootCmd.Flags().StringSliceVar(&expect, "expect", nil,
+		"Records the servers should return once a change has propagated (default the most common result)")
+	rootCmd.Flags().StringVar(&queryFormat, "format", "text", "Output format (text, json)")
+	rootCmd.Flags().BoolVar(&authBaseline, "authoritative", false,
+		"Compare the results to the answer from the domain's authoritative servers")
+	rootCmd.Flags().BoolVar(&checkDNSSEC, "dnssec", false,
+		"Request DNSSEC signatures and check whether each server validates them")
+	rootCmd.Flags().BoolVar(&validate, "validate", false, "Validate the DNSSEC signatures on each server's answer")
+	rootCmd.Flags().StringVar(&trustAnchor, "trust-anchor", "",
+		"File of DS or DNSKEY records to validate from (default the root zone's keys)")
+	rootCmd.Flags().StringVar(&validateWith, "validate-resolver", dnsyo.DefaultValidationResolver,
+		"Resolver used to fetch the DS and DNSKEY records when validating")
+	rootCmd.Flags().StringSliceVar(&ecsPrefixes, "ecs", nil,
+		"Send an EDNS0 client subnet and compare the answers for each subnet given, e.g. 192.0.2.0/24")
+	rootCmd.Flags().BoolVar(&requestNSID, "nsid", false, "Ask each server for the NSID of the instance that answered")
+	rootCmd.Flags().BoolVar(&queryChaos, "chaos", false,
+		"Identify instances with id.server and hostname.bind CHAOS queries when they do not return an NSID "+
+			"(sent separately, so they may reach a different anycast instance)")
+	rootCmd.Flags().StringSliceVar(&groupBy, "group-by", nil,
+		"Also group the results by parts of the response (flags, authority, additional, chain, instance)")
 }
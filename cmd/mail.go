@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/tomtom5152/dnsyo/dnsyo"
+	"os"
+)
+
+var (
+	mailSelectors []string
+	mailFormat    string
+)
+
+// mailCmd represents the mail command
+var mailCmd = &cobra.Command{
+	Use:   "mail <domain>",
+	Short: "Check the propagation of a domain's email authentication records",
+	Long: `Queries the SPF, DMARC and MTA-STS records of a domain, and its DKIM keys for each --selector, across the
+resolver sample. Each policy is parsed and checked, and the servers are grouped by the policy they see so that the
+propagation of a change is clear.
+
+The command exits with a non-zero status if any of the policies have syntax errors.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mc, err := dnsyo.NewMailCheck(args[0], mailSelectors)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+
+		sl := selectServers(cmd)
+		sl.ExecuteBatch(mc.Batch, numThreads)
+
+		switch mailFormat {
+		case "json":
+			text, err := mc.ToJSON()
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+			fmt.Println(text)
+
+		case "text":
+			print(mc.ToTextSummary())
+
+		default:
+			log.Fatalf("unknown format %s", mailFormat)
+		}
+
+		if len(mc.Problems()) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mailCmd)
+
+	mailCmd.Flags().StringSliceVarP(&mailSelectors, "selector", "s", nil, "DKIM selectors to check the keys of")
+	mailCmd.Flags().StringVar(&mailFormat, "format", "text", "Output format (text, json)")
+
+	addServerFlags(mailCmd)
+}
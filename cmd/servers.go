@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/tomtom5152/dnsyo/dnsyo"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	serversFormat   string
+	listCountry     string
+	listContinent   string
+	listTag         string
+	listSoftware    string
+	listDNSSEC      bool
+	listPinned      bool
+	listDisabled    bool
+	listAllStatuses bool
+)
+
+// serversCmd represents the servers command
+var serversCmd = &cobra.Command{
+	Use:   "servers",
+	Short: "Inspect and curate the list of resolvers",
+	Long: `Commands for inspecting the resolver file and curating the servers in it.
+
+Changes made with these commands are kept when the list is next updated.`,
+}
+
+// serversListCmd represents the servers list command
+var serversListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the servers in the resolver file",
+	Run: func(cmd *cobra.Command, args []string) {
+		sl := loadServers()
+
+		sl = sl.Filter(func(s dnsyo.Server) bool {
+			switch {
+			case listCountry != "" && !strings.EqualFold(s.Country, listCountry):
+				return false
+			case listContinent != "" && !strings.EqualFold(s.Continent(), listContinent):
+				return false
+			case listTag != "" && !s.HasTag(listTag):
+				return false
+			case listSoftware != "" && !strings.Contains(strings.ToLower(s.Software), strings.ToLower(listSoftware)):
+				return false
+			case listDNSSEC && !s.DNSSEC:
+				return false
+			case listPinned && !s.Pinned:
+				return false
+			case listDisabled && !s.Disabled:
+				return false
+			case !listDisabled && !listAllStatuses && s.Disabled:
+				return false
+			}
+			return true
+		})
+
+		switch serversFormat {
+		case "json":
+			text, err := json.Marshal(sl)
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+			fmt.Println(string(text))
+
+		case "table":
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "IP\tCOUNTRY\tNAME\tSOFTWARE\tFLAGS\tTAGS")
+			for _, s := range sl {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					s.IP, s.Country, s.Name, s.Software, serverFlags(s), strings.Join(s.Tags, ","))
+			}
+			w.Flush()
+
+		default:
+			log.Fatalf("unknown format %s", serversFormat)
+		}
+	},
+}
+
+// serversStatsCmd represents the servers stats command
+var serversStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Count the servers in the resolver file by location and capabilities",
+	Run: func(cmd *cobra.Command, args []string) {
+		sl := loadServers()
+		st := sl.Stats()
+
+		switch serversFormat {
+		case "json":
+			text, err := st.ToJSON()
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+			fmt.Println(text)
+
+		case "table", "text":
+			print(st.ToTextSummary())
+
+		default:
+			log.Fatalf("unknown format %s", serversFormat)
+		}
+	},
+}
+
+// serversTestCmd represents the servers test command
+var serversTestCmd = &cobra.Command{
+	Use:   "test <ip>",
+	Short: "Test a single server and show the full result of each test query",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := dnsyo.Server{IP: args[0]}
+		if sl, err := dnsyo.ServersFromFile(resolverfile); err == nil {
+			if i := sl.Find(args[0]); i >= 0 {
+				s = sl[i]
+			}
+		}
+
+		fmt.Printf("Testing %s (%s)\n\n", s.String(), s.IP)
+		for _, d := range s.Diagnose() {
+			fmt.Printf("%s\n", d.Question)
+			fmt.Printf("  time: %s\n", d.RTT)
+			if d.Error != "" {
+				fmt.Printf("  error: %s\n\n", d.Error)
+				continue
+			}
+			fmt.Printf("  status: %s, flags: %s\n", d.Rcode, d.Flags)
+			for _, a := range d.Answers {
+				fmt.Printf("  %s\n", a)
+			}
+			fmt.Println()
+		}
+
+		ok, err := s.Test()
+		if ok {
+			fmt.Println("Server passed the update test")
+		} else {
+			fmt.Printf("Server failed the update test: %s\n", err)
+		}
+	},
+}
+
+// serversFingerprintCmd represents the servers fingerprint command
+var serversFingerprintCmd = &cobra.Command{
+	Use:   "fingerprint [ip]...",
+	Short: "Identify the software servers are running",
+	Long: `Identifies the software of the given servers, or of every enabled server, from the version they report to
+the version.bind and version.server CHAOS TXT queries and saves it in the resolver file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sl := loadServers()
+
+		var toCheck dnsyo.ServerList
+		if len(args) == 0 {
+			toCheck = sl.Enabled()
+		}
+		for _, ip := range args {
+			i := sl.Find(ip)
+			if i < 0 {
+				log.Fatalf("server %s is not in the list", ip)
+			}
+			toCheck = append(toCheck, sl[i])
+		}
+
+		identified := toCheck.FingerprintAll(numThreads)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "IP\tNAME\tSOFTWARE")
+		for _, s := range toCheck {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.IP, s.Name, s.Software)
+			sl[sl.Find(s.IP)].Software = s.Software
+		}
+		w.Flush()
+		fmt.Printf("\nIdentified the software of %d of %d servers\n", identified, len(toCheck))
+
+		saveServers(sl)
+	},
+}
+
+// loadServers reads the resolver file, exiting if it cannot be read.
+func loadServers() dnsyo.ServerList {
+	sl, err := dnsyo.ServersFromFile(resolverfile)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	return sl
+}
+
+// saveServers validates and writes the list back to the resolver file, exiting if it cannot be written.
+func saveServers(sl dnsyo.ServerList) {
+	if err := sl.Validate(); err != nil {
+		log.Fatal(err.Error())
+	}
+	if err := sl.DumpToFile(resolverfile); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// serverFlags produces a short description of the curation applied to a server for use in tables.
+func serverFlags(s dnsyo.Server) string {
+	var flags []string
+	if s.Pinned {
+		flags = append(flags, "pinned")
+	}
+	if s.Disabled {
+		flags = append(flags, "disabled")
+	}
+	if s.DNSSEC {
+		flags = append(flags, "dnssec")
+	}
+	return strings.Join(flags, ",")
+}
+
+func init() {
+	rootCmd.AddCommand(serversCmd)
+	serversCmd.AddCommand(serversListCmd, serversStatsCmd, serversTestCmd, serversFingerprintCmd)
+
+	serversCmd.PersistentFlags().StringVar(&serversFormat, "format", "table", "Output format (table, json)")
+
+	serversListCmd.Flags().StringVarP(&listCountry, "country", "c", "", "Only list servers in a two letter country code")
+	serversListCmd.Flags().StringVar(&listContinent, "continent", "", "Only list servers in a two letter continent code")
+	serversListCmd.Flags().StringVar(&listTag, "tag", "", "Only list servers with a tag")
+	serversListCmd.Flags().StringVar(&listSoftware, "software", "", "Only list servers running software containing this name")
+	serversListCmd.Flags().BoolVar(&listDNSSEC, "dnssec", false, "Only list servers that support DNSSEC")
+	serversListCmd.Flags().BoolVar(&listPinned, "pinned", false, "Only list pinned servers")
+	serversListCmd.Flags().BoolVar(&listDisabled, "disabled", false, "Only list disabled servers")
+	serversListCmd.Flags().BoolVar(&listAllStatuses, "all", false, "Include disabled servers")
+}
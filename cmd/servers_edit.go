@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/tomtom5152/dnsyo/dnsyo"
+	"strings"
+)
+
+var (
+	addName    string
+	addCountry string
+	addTags    []string
+	addPin     bool
+)
+
+// serversAddCmd represents the servers add command
+var serversAddCmd = &cobra.Command{
+	Use:   "add <ip>",
+	Short: "Add a server to the resolver file",
+	Long: `Adds a server to the resolver file without testing it.
+Servers that are added by hand should be pinned to keep them when the list is next updated.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sl := loadServers()
+		if sl.Find(args[0]) >= 0 {
+			log.Fatalf("server %s is already in the list", args[0])
+		}
+
+		sl = append(sl, dnsyo.Server{
+			IP:      args[0],
+			Name:    addName,
+			Country: strings.ToUpper(addCountry),
+			Tags:    addTags,
+			Pinned:  addPin,
+		})
+		saveServers(sl)
+	},
+}
+
+// serversRemoveCmd represents the servers remove command
+var serversRemoveCmd = &cobra.Command{
+	Use:   "remove <ip>...",
+	Short: "Remove servers from the resolver file",
+	Long: `Removes servers from the resolver file.
+Servers that are still in the list of nameservers will be added back when the list is next updated, use disable to
+prevent a server from being used permanently.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sl := loadServers()
+		for _, ip := range args {
+			i := sl.Find(ip)
+			if i < 0 {
+				log.Fatalf("server %s is not in the list", ip)
+			}
+			sl = append(sl[:i], sl[i+1:]...)
+		}
+		saveServers(sl)
+	},
+}
+
+var (
+	serversDisableCmd = editServersCommand("disable", "Stop servers from being queried", func(s *dnsyo.Server) {
+		s.Disabled = true
+	})
+	serversEnableCmd = editServersCommand("enable", "Allow disabled servers to be queried again", func(s *dnsyo.Server) {
+		s.Disabled = false
+	})
+	serversPinCmd = editServersCommand("pin", "Always include servers when querying", func(s *dnsyo.Server) {
+		s.Pinned = true
+	})
+	serversUnpinCmd = editServersCommand("unpin", "Stop always including servers when querying", func(s *dnsyo.Server) {
+		s.Pinned = false
+	})
+)
+
+// serversTagCmd represents the servers tag command
+var serversTagCmd = &cobra.Command{
+	Use:   "tag <ip> <tag>...",
+	Short: "Add tags to a server",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		editServers(args[:1], func(s *dnsyo.Server) {
+			for _, t := range args[1:] {
+				if !s.HasTag(t) {
+					s.Tags = append(s.Tags, t)
+				}
+			}
+		})
+	},
+}
+
+// serversUntagCmd represents the servers untag command
+var serversUntagCmd = &cobra.Command{
+	Use:   "untag <ip> <tag>...",
+	Short: "Remove tags from a server",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		editServers(args[:1], func(s *dnsyo.Server) {
+			var tags []string
+			for _, t := range s.Tags {
+				keep := true
+				for _, remove := range args[1:] {
+					if strings.EqualFold(t, remove) {
+						keep = false
+					}
+				}
+				if keep {
+					tags = append(tags, t)
+				}
+			}
+			s.Tags = tags
+		})
+	},
+}
+
+// editServersCommand creates a command that applies the same change to each of the servers given as arguments.
+func editServersCommand(use, short string, edit func(s *dnsyo.Server)) *cobra.Command {
+	return &cobra.Command{
+		Use:   fmt.Sprintf("%s <ip>...", use),
+		Short: short,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			editServers(args, edit)
+		},
+	}
+}
+
+// editServers loads the resolver file, applies the change to each server by IP and saves it again.
+func editServers(ips []string, edit func(s *dnsyo.Server)) {
+	sl := loadServers()
+	for _, ip := range ips {
+		i := sl.Find(ip)
+		if i < 0 {
+			log.Fatalf("server %s is not in the list", ip)
+		}
+		edit(&sl[i])
+	}
+	saveServers(sl)
+}
+
+func init() {
+	serversCmd.AddCommand(serversAddCmd, serversRemoveCmd, serversDisableCmd, serversEnableCmd, serversPinCmd,
+		serversUnpinCmd, serversTagCmd, serversUntagCmd)
+
+	serversAddCmd.Flags().StringVar(&addName, "name", "", "Hostname of the server")
+	serversAddCmd.Flags().StringVarP(&addCountry, "country", "c", "", "Two letter country code of the server")
+	serversAddCmd.Flags().StringSliceVar(&addTags, "tag", nil, "Tags to give the server")
+	serversAddCmd.Flags().BoolVar(&addPin, "pin", false, "Pin the server so it is always queried and kept on update")
+}
@@ -19,10 +19,25 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/tomtom5152/dnsyo/dnsyo"
+	"io/ioutil"
+	"os"
+	"time"
 )
 
 var (
-	csvURL string
+	csvURL       string
+	reportFile   string
+	reportFormat string
+	diffPrevious bool
+	dryRun       bool
+	stateFile    string
+	incremental  bool
+	staleAfter   time.Duration
+	minScore     float64
+	csvCache     string
+	offline      bool
+	maxTestRTT   time.Duration
+	fingerprint  bool
 )
 
 // updateCmd represents the update command
@@ -30,23 +45,112 @@ var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Update the list of resolvers",
 	Long: `Performs a test query on all of the configured name servers to see if they are working and saves the output
-to the list of active servers.`,
+to the list of active servers.
+
+A report of the servers added, removed and disabled is produced, compared against the existing resolver file.
+Use --dry-run to review the report without replacing the resolver file.
+
+The outcome of each test is kept in a state file alongside the resolver file, and servers are only dropped once their
+score over recent tests falls below --min-score. With --incremental, only servers that have not been tested within
+--stale are tested again. Servers with a median response time slower than --max-rtt can also be dropped.
+
+The downloaded list of nameservers is cached and only downloaded again when it changes. Use --offline to test the
+servers in the cached copy without downloading it.
+
+With --fingerprint, the software of each working server is identified from its CHAOS version queries.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		toTest, err := dnsyo.ServersFromCSVURL(csvURL)
+		if reportFormat != "text" && reportFormat != "json" {
+			log.Fatalf("unknown report format %s", reportFormat)
+		}
+
+		previous, err := dnsyo.ServersFromFile(resolverfile)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatal(err.Error())
+		}
+
+		if csvCache == "" {
+			csvCache = resolverfile + ".csv"
+		}
+		fetcher := dnsyo.NewCSVFetcher(csvURL, csvCache)
+		fetcher.Offline = offline
+
+		toTest, err := fetcher.Servers()
 		if err != nil {
 			log.Fatal(err.Error())
 			return
 		}
 
-		fmt.Printf("Testing %d nameservers\n", len(toTest))
-		working := toTest.TestAll(numThreads)
-		err = working.DumpToFile(resolverfile)
+		if stateFile == "" {
+			stateFile = resolverfile + ".state.json"
+		}
+		history, err := dnsyo.HistoryFromFile(stateFile)
 		if err != nil {
 			log.Fatal(err.Error())
-			return
 		}
 
-		log.Info("Updated server list, %d active, %d disabled\n", len(working), len(toTest)-len(working))
+		var stale time.Duration
+		if incremental {
+			stale = staleAfter
+		}
+
+		fmt.Printf("Testing %d nameservers\n", len(toTest))
+		working, failures := toTest.TestAllWithHistory(numThreads, history, minScore, stale)
+		if removed := history.Prune(toTest); removed > 0 {
+			log.Infof("Removed the history of %d servers that are no longer listed", removed)
+		}
+		working.MergeCuration(previous)
+
+		if maxTestRTT > 0 {
+			var slow dnsyo.ServerList
+			working, slow = working.SplitRTT(history, maxTestRTT)
+			if len(slow) > 0 {
+				failures["SLOWER THAN "+maxTestRTT.String()] = slow
+			}
+		}
+
+		if fingerprint {
+			fmt.Printf("Fingerprinting %d nameservers\n", len(working))
+			identified := working.FingerprintAll(numThreads)
+			log.Infof("Identified the software of %d servers", identified)
+		}
+
+		if !dryRun {
+			err = working.DumpToFile(resolverfile)
+			if err != nil {
+				log.Fatal(err.Error())
+				return
+			}
+
+			err = history.DumpToFile(stateFile)
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+
+			log.Infof("Updated server list, %d active, %d disabled", len(working), len(toTest)-len(working))
+		}
+
+		if !diffPrevious {
+			previous = nil
+		}
+		report := dnsyo.NewUpdateReport(previous, working, failures)
+		var text string
+		if reportFormat == "json" {
+			text, err = report.ToJSON()
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+		} else {
+			text = report.ToTextSummary()
+		}
+
+		if reportFile != "" {
+			err = ioutil.WriteFile(reportFile, []byte(text), 0644)
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+		} else {
+			fmt.Println(text)
+		}
 
 		return
 	},
@@ -65,4 +169,16 @@ func init() {
 	// is called directly, e.g.:
 	// updateCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	updateCmd.Flags().StringVar(&csvURL, "csvurl", "https://public-dns.info/nameservers.csv", "URL to fetch the list form")
+	updateCmd.Flags().StringVar(&csvCache, "csvcache", "", "Location of the cached copy of the list (default <resolverfile>.csv)")
+	updateCmd.Flags().BoolVar(&offline, "offline", false, "Use the cached copy of the list instead of downloading it")
+	updateCmd.Flags().StringVar(&reportFile, "report", "", "File to write the update report to (default stdout)")
+	updateCmd.Flags().StringVar(&reportFormat, "format", "text", "Format of the update report (text, json)")
+	updateCmd.Flags().BoolVar(&diffPrevious, "diff", true, "Compare the results against the existing resolver file")
+	updateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Produce the report without replacing the resolver file")
+	updateCmd.Flags().StringVar(&stateFile, "statefile", "", "Location of the resolver health history (default <resolverfile>.state.json)")
+	updateCmd.Flags().BoolVar(&incremental, "incremental", false, "Only test servers that have not been tested recently")
+	updateCmd.Flags().DurationVar(&staleAfter, "stale", 24*time.Hour, "Age after which a server is tested again in incremental mode")
+	updateCmd.Flags().DurationVar(&maxTestRTT, "max-rtt", 0, "Drop servers with a median response time slower than this (0=no limit)")
+	updateCmd.Flags().BoolVar(&fingerprint, "fingerprint", false, "Identify the software each working server is running")
+	updateCmd.Flags().Float64Var(&minScore, "min-score", dnsyo.DefaultMinScore, "Minimum health score (0-1) for a server to be kept")
 }
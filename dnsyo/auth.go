@@ -0,0 +1,453 @@
+package dnsyo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxReferrals limits how many delegations are followed from the root before giving up.
+const maxReferrals = 16
+
+// RootServers are the IPv4 addresses of the root nameservers, where the delegation to a zone is followed from.
+var RootServers = ServerList{
+	{IP: "198.41.0.4", Name: "a.root-servers.net"},
+	{IP: "170.247.170.2", Name: "b.root-servers.net"},
+	{IP: "192.33.4.12", Name: "c.root-servers.net"},
+	{IP: "199.7.91.13", Name: "d.root-servers.net"},
+	{IP: "192.203.230.10", Name: "e.root-servers.net"},
+	{IP: "192.5.5.241", Name: "f.root-servers.net"},
+	{IP: "192.112.36.4", Name: "g.root-servers.net"},
+	{IP: "198.97.190.53", Name: "h.root-servers.net"},
+	{IP: "192.36.148.17", Name: "i.root-servers.net"},
+	{IP: "192.58.128.30", Name: "j.root-servers.net"},
+	{IP: "193.0.14.129", Name: "k.root-servers.net"},
+	{IP: "199.7.83.42", Name: "l.root-servers.net"},
+	{IP: "202.12.27.33", Name: "m.root-servers.net"},
+}
+
+// AuthServer is a single address of one of a zone's authoritative nameservers and what it reported for the zone.
+type AuthServer struct {
+	Name   string
+	IP     string
+	Serial uint32 `json:",omitempty"`
+
+	// TTL is the TTL the server gives the answer to the query, or the negative caching time if there is no answer
+	TTL uint32 `json:",omitempty"`
+
+	// Lame is the reason the server did not answer authoritatively for the zone, or empty if it did
+	Lame string `json:",omitempty"`
+
+	// NotChecked is the reason the server could not be reached from here, such as an IPv6 address on a host without
+	// IPv6. These servers are left out of the comparison rather than reported as lame.
+	NotChecked string `json:",omitempty"`
+}
+
+// AuthCheck is the outcome of querying every authoritative nameserver for a zone directly.
+type AuthCheck struct {
+	Zone    string
+	Servers []AuthServer
+
+	// Query holds the answer each server gave, keyed by the server name and IP
+	Query *Query
+}
+
+// FindNameservers discovers the zone containing domain and returns every IPv4 and IPv6 address of its authoritative
+// nameservers, with the name of the nameserver as the server name.
+//
+// The delegation is followed from the RootServers unless resolver is set, in which case the zone is looked up through
+// that recursive resolver instead. Nameservers without glue records are resolved with the system resolver.
+func FindNameservers(domain, resolver string) (zone string, sl ServerList, err error) {
+	if resolver != "" {
+		return findNameserversRecursive(domain, Server{IP: resolver})
+	}
+
+	servers := RootServers
+	for i := 0; i < maxReferrals; i++ {
+		resp, err := sendAny(servers, newQuestion(domain, dns.TypeSOA, false))
+		if err != nil {
+			return "", nil, err
+		}
+
+		if resp.Authoritative {
+			zone = soaOwner(resp)
+			if zone == "" {
+				return "", nil, fmt.Errorf("unable to find the zone for %s", domain)
+			}
+
+			resp, err = sendAny(servers, newQuestion(zone, dns.TypeNS, false))
+			if err != nil {
+				return "", nil, err
+			}
+			names, glue := nameservers(resp)
+			return zone, addresses(names, glue), nil
+		}
+
+		names, glue := nameservers(resp)
+		if len(names) == 0 {
+			return "", nil, fmt.Errorf("no referral or answer for %s", domain)
+		}
+		servers = addresses(names, glue)
+		if len(servers) == 0 {
+			return "", nil, fmt.Errorf("unable to find the address of any of %s", strings.Join(names, ", "))
+		}
+	}
+
+	return "", nil, fmt.Errorf("too many referrals for %s", domain)
+}
+
+// findNameserversRecursive does the work of FindNameservers using a recursive resolver.
+func findNameserversRecursive(domain string, resolver Server) (zone string, sl ServerList, err error) {
+	resp, _, err := resolver.exchange(domain, dns.TypeSOA)
+	if resp == nil {
+		return "", nil, err
+	}
+	zone = soaOwner(resp)
+	if zone == "" {
+		return "", nil, fmt.Errorf("unable to find the zone for %s", domain)
+	}
+
+	resp, _, err = resolver.exchange(zone, dns.TypeNS)
+	if err != nil {
+		return "", nil, err
+	}
+	names, _ := nameservers(resp)
+
+	for _, name := range names {
+		for _, t := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			ips, _, _ := resolver.Lookup(name, t)
+			for _, ip := range ips {
+				if net.ParseIP(ip) != nil {
+					sl = append(sl, Server{IP: ip, Name: name})
+				}
+			}
+		}
+	}
+
+	return zone, sl, nil
+}
+
+// sendAny sends the message to each of the servers in turn until one of them responds.
+func sendAny(sl ServerList, msg *dns.Msg) (resp *dns.Msg, err error) {
+	for _, s := range sl {
+		resp, _, err = s.send(msg)
+		if resp != nil {
+			return resp, nil
+		}
+	}
+	if err == nil {
+		err = errors.New("no servers to query")
+	}
+	return nil, err
+}
+
+// soaOwner finds the name of the zone from the SOA record in either the answer or authority section of a response.
+func soaOwner(resp *dns.Msg) string {
+	for _, rr := range append(resp.Answer, resp.Ns...) {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Hdr.Name
+		}
+	}
+	return ""
+}
+
+// nameservers collects the NS records from the answer and authority sections of a response, along with any
+// addresses for them in the additional section.
+func nameservers(resp *dns.Msg) (names []string, glue map[string][]string) {
+	glue = make(map[string][]string)
+	seen := make(map[string]bool)
+
+	for _, rr := range append(resp.Answer, resp.Ns...) {
+		if ns, ok := rr.(*dns.NS); ok && !seen[strings.ToLower(ns.Ns)] {
+			seen[strings.ToLower(ns.Ns)] = true
+			names = append(names, ns.Ns)
+		}
+	}
+
+	for _, rr := range resp.Extra {
+		name := strings.ToLower(rr.Header().Name)
+		switch a := rr.(type) {
+		case *dns.A:
+			glue[name] = append(glue[name], a.A.String())
+		case *dns.AAAA:
+			glue[name] = append(glue[name], a.AAAA.String())
+		}
+	}
+
+	return
+}
+
+// addresses lists every address of the named nameservers, using the system resolver to add to the glue.
+func addresses(names []string, glue map[string][]string) (sl ServerList) {
+	for _, name := range names {
+		ips := glue[strings.ToLower(name)]
+		if found, err := net.LookupIP(name); err == nil {
+			for _, ip := range found {
+				ips = append(ips, ip.String())
+			}
+		}
+
+		host := strings.TrimSuffix(name, ".")
+		for _, ip := range ips {
+			if sl.Find(ip) < 0 {
+				sl = append(sl, Server{IP: ip, Name: host})
+			}
+		}
+	}
+	return
+}
+
+// String is the name used for the server in the query results, including the IP as several addresses share a name.
+func (as AuthServer) String() string {
+	return fmt.Sprintf("%s (%s)", as.Name, as.IP)
+}
+
+// CheckAuthoritative queries each of the servers directly without recursion, asking for the zone's SOA record and
+// the record in the query. Servers that do not answer authoritatively for the zone are reported as lame, and those
+// that cannot be reached because of the network here are not checked.
+func (sl *ServerList) CheckAuthoritative(zone string, q *Query) *AuthCheck {
+	ac := &AuthCheck{
+		Zone:    zone,
+		Servers: make([]AuthServer, len(*sl)),
+		Query:   &Query{Domain: q.Domain, IDN: q.IDN, Type: q.Type, Class: q.Class, Results: make(QueryResults)},
+	}
+
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+
+	for i, s := range *sl {
+		wg.Add(1)
+		go func(i int, s Server) {
+			defer wg.Done()
+
+			as := AuthServer{Name: s.Name, IP: s.IP}
+			resp, _, err := s.send(newQuestion(zone, dns.TypeSOA, false))
+			switch {
+			case isLocalNetworkError(err):
+				as.NotChecked = err.Error()
+				mtx.Lock()
+				ac.Servers[i] = as
+				mtx.Unlock()
+				return
+			case err != nil:
+				as.Lame = err.Error()
+			case !resp.Authoritative:
+				as.Lame = "NOT AUTHORITATIVE"
+			default:
+				if soa, ok := resp.Answer[0].(*dns.SOA); ok {
+					as.Serial = soa.Serial
+				} else {
+					as.Lame = "NO SOA"
+				}
+			}
+
+			resp, rtt, err := s.send(q.authMessage())
+			r := newResult(s, resp, rtt, err)
+			// authoritative servers are the source of the records rather than caching them
+			as.TTL, r.TTL = r.TTL, 0
+
+			mtx.Lock()
+			ac.Servers[i] = as
+			ac.Query.Results[as.String()] = r
+			mtx.Unlock()
+		}(i, s)
+	}
+
+	wg.Wait()
+	return ac
+}
+
+// isLocalNetworkError reports whether a server could not be reached because of the network the query was sent from,
+// rather than because of the server.
+func isLocalNetworkError(err error) bool {
+	le, ok := err.(*LookupError)
+	return ok && (le.Code == CodeUnreachable || le.Code == CodeNetwork)
+}
+
+// authMessage is the question for the record in the query, asked of an authoritative server without recursion and in
+// the query's class, so that the baseline is for the same records as the resolvers are asked for.
+func (q *Query) authMessage() *dns.Msg {
+	msg := newQuestion(q.Domain, q.Type, false)
+	if q.Class != 0 {
+		msg.Question[0].Qclass = q.Class
+	}
+	return msg
+}
+
+// answer gives the normalised answer of a server, or false if the server is lame, was not checked or gave an error
+// that is not cached. Errors that can be cached such as NXDOMAIN are answers.
+func (ac *AuthCheck) answer(as AuthServer) (string, bool) {
+	r, ok := ac.Query.Results[as.String()]
+	if as.Lame != "" || !ok {
+		return "", false
+	}
+	if r.Error != "" {
+		return r.Error, as.TTL > 0
+	}
+	// servers only give the final records of an alias if the target is in their zone, so the alias is compared instead
+	if alias, ok := aliasAnswer(r); ok {
+		return alias, true
+	}
+	return normaliseAnswer(r.Answer), true
+}
+
+// Answers gives each answer of the servers that were checked and are not lame and the longest TTL they give it.
+func (ac *AuthCheck) Answers() map[string]uint32 {
+	answers := make(map[string]uint32)
+	for _, as := range ac.Servers {
+		a, ok := ac.answer(as)
+		if !ok {
+			continue
+		}
+		if ttl, seen := answers[a]; !seen || as.TTL > ttl {
+			answers[a] = as.TTL
+		}
+	}
+	return answers
+}
+
+// Baseline is the answer given by most of the servers that were checked and are not lame, and the longest TTL they give it.
+// Errors that can be cached such as NXDOMAIN are also a valid baseline.
+func (ac *AuthCheck) Baseline() (answer string, ttl uint32, err error) {
+	counts := make(map[string]int)
+
+	for _, as := range ac.Servers {
+		a, ok := ac.answer(as)
+		if !ok {
+			continue
+		}
+
+		counts[a]++
+		if answer == "" || counts[a] > counts[answer] || counts[a] == counts[answer] && a < answer {
+			answer = a
+		}
+	}
+
+	if answer == "" {
+		return "", 0, fmt.Errorf("none of the authoritative servers for %s gave an answer", ac.Zone)
+	}
+	return answer, ac.Answers()[answer], nil
+}
+
+// ExpectAuthoritative resolves the query using the authoritative servers for the domain and sets the expected result
+// to their answer, so that the results can be classified against it. The resolver is used to find the nameservers
+// as in FindNameservers.
+func (q *Query) ExpectAuthoritative(resolver string) (*AuthCheck, error) {
+	zone, sl, err := FindNameservers(q.Domain, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	ac := sl.CheckAuthoritative(zone, q)
+	q.Expected, q.ExpectedTTL, err = ac.Baseline()
+	q.Authoritative = ac.Answers()
+	q.CompareAlias = strings.HasPrefix(q.Expected, "CNAME ")
+	return ac, err
+}
+
+// Serials groups the servers that were checked and are not lame by the serial number they reported.
+func (ac *AuthCheck) Serials() map[uint32][]string {
+	serials := make(map[uint32][]string)
+	for _, as := range ac.Servers {
+		if as.Lame == "" && as.NotChecked == "" {
+			serials[as.Serial] = append(serials[as.Serial], as.String())
+		}
+	}
+	return serials
+}
+
+// Problems lists the lame delegations and any disagreement between the servers, or nothing if they are consistent.
+// Servers that were not checked are not problems, as they could not be reached because of the network here.
+func (ac *AuthCheck) Problems() (problems []string) {
+	for _, as := range ac.Servers {
+		if as.Lame != "" {
+			problems = append(problems, fmt.Sprintf("lame delegation to %s: %s", as.String(), as.Lame))
+		}
+	}
+
+	if serials := ac.Serials(); len(serials) > 1 {
+		var found []string
+		for serial := range serials {
+			found = append(found, fmt.Sprint(serial))
+		}
+		sort.Strings(found)
+		problems = append(problems, fmt.Sprintf("servers have different serials: %s", strings.Join(found, ", ")))
+	}
+
+	answers := make(map[string]bool)
+	for _, r := range ac.Query.Results {
+		answer := r.Answer
+		if r.Error != "" {
+			answer = r.Error
+		}
+		answers[normaliseAnswer(answer)] = true
+	}
+	if len(answers) > 1 {
+		problems = append(problems, fmt.Sprintf("servers gave %d different answers", len(answers)))
+	}
+
+	return
+}
+
+// ToTextSummary prints the answers given by the servers in the same format as a recursive query, followed by the
+// serial each server reported and any problems found.
+func (ac *AuthCheck) ToTextSummary() (text string) {
+	text = ac.Query.ToTextSummary()
+	text += fmt.Sprintf("\n - AUTHORITATIVE SERVERS\nZone %s has %d nameserver addresses\n\n", ac.Zone, len(ac.Servers))
+
+	serials := ac.Serials()
+	var sorted []uint32
+	for serial := range serials {
+		sorted = append(sorted, serial)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	for _, serial := range sorted {
+		sort.Strings(serials[serial])
+		text += fmt.Sprintf("%d servers have serial %d;\n%s\n\n", len(serials[serial]), serial,
+			strings.Join(serials[serial], "\n"))
+	}
+
+	var unchecked []string
+	for _, as := range ac.Servers {
+		if as.NotChecked != "" {
+			unchecked = append(unchecked, fmt.Sprintf("%s: %s", as.String(), as.NotChecked))
+		}
+	}
+	if len(unchecked) > 0 {
+		sort.Strings(unchecked)
+		text += fmt.Sprintf("%d servers could not be reached from here and were not checked;\n%s\n\n", len(unchecked),
+			strings.Join(unchecked, "\n"))
+	}
+
+	if problems := ac.Problems(); len(problems) > 0 {
+		text += fmt.Sprint("\nAnd here are the problems;\n\n")
+		for _, p := range problems {
+			text += p + "\n"
+		}
+	} else {
+		text += "All of the servers agree\n"
+	}
+
+	return text
+}
+
+// ToJSON prints a verbose JSON representation of the check, including the problems found.
+func (ac *AuthCheck) ToJSON() (string, error) {
+	text, err := json.Marshal(struct {
+		Zone     string
+		Domain   string
+		IDN      string `json:",omitempty"`
+		Type     string
+		Servers  []AuthServer
+		Results  QueryResults
+		Problems []string
+	}{ac.Zone, ac.Query.Domain, ac.Query.IDN, ac.Query.GetType(), ac.Servers, ac.Query.Results, ac.Problems()})
+	return string(text), err
+}
@@ -0,0 +1,162 @@
+package dnsyo
+
+import (
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestSoaOwner(t *testing.T) {
+	soa, _ := dns.NewRR("example.test. 3600 IN SOA ns1.example.test. hostmaster.example.test. 1 7200 3600 86400 900")
+
+	Convey("the zone is found from the answer or authority section", t, func() {
+		m := new(dns.Msg)
+		So(soaOwner(m), ShouldBeEmpty)
+
+		m.Ns = []dns.RR{soa}
+		So(soaOwner(m), ShouldEqual, "example.test.")
+
+		m.Ns, m.Answer = nil, []dns.RR{soa}
+		So(soaOwner(m), ShouldEqual, "example.test.")
+	})
+}
+
+func TestNameservers(t *testing.T) {
+	ns1, _ := dns.NewRR("example.test. 3600 IN NS ns1.example.test.")
+	ns2, _ := dns.NewRR("example.test. 3600 IN NS NS2.example.test.")
+	dup, _ := dns.NewRR("example.test. 3600 IN NS ns2.example.test.")
+	a, _ := dns.NewRR("ns1.example.test. 3600 IN A 192.0.2.1")
+	aaaa, _ := dns.NewRR("NS1.example.test. 3600 IN AAAA 2001:db8::1")
+
+	Convey("a referral gives the nameserver names and their glue", t, func() {
+		m := new(dns.Msg)
+		m.Ns = []dns.RR{ns1, ns2, dup}
+		m.Extra = []dns.RR{a, aaaa}
+
+		names, glue := nameservers(m)
+		So(names, ShouldResemble, []string{"ns1.example.test.", "NS2.example.test."})
+		So(glue, ShouldResemble, map[string][]string{"ns1.example.test.": {"192.0.2.1", "2001:db8::1"}})
+	})
+}
+
+func TestQuery_authMessage(t *testing.T) {
+	Convey("authoritative servers are asked without recursion in the query's class", t, func() {
+		q := &Query{Domain: "version.bind", Type: dns.TypeTXT}
+		msg := q.authMessage()
+		So(msg.RecursionDesired, ShouldBeFalse)
+		So(msg.Question[0].Qclass, ShouldEqual, dns.ClassINET)
+
+		q.Class = dns.ClassCHAOS
+		So(q.authMessage().Question[0].Qclass, ShouldEqual, dns.ClassCHAOS)
+	})
+}
+
+func TestAuthCheck(t *testing.T) {
+	ns1 := AuthServer{Name: "ns1.example.test", IP: "192.0.2.1", Serial: 2018010101}
+	ns1v6 := AuthServer{Name: "ns1.example.test", IP: "2001:db8::1", Serial: 2018010101}
+	ns2 := AuthServer{Name: "ns2.example.test", IP: "192.0.2.2", Serial: 2018010101}
+
+	ac := &AuthCheck{
+		Zone:    "example.test.",
+		Servers: []AuthServer{ns1, ns1v6, ns2},
+		Query: &Query{
+			Domain: "www.example.test",
+			Type:   dns.TypeA,
+			Results: QueryResults{
+				ns1.String():   &Result{Answer: "192.0.2.80"},
+				ns1v6.String(): &Result{Answer: "192.0.2.80"},
+				ns2.String():   &Result{Answer: "192.0.2.80"},
+			},
+		},
+	}
+
+	Convey("consistent servers have no problems", t, func() {
+		So(ns1v6.String(), ShouldEqual, "ns1.example.test (2001:db8::1)")
+		So(ac.Serials(), ShouldHaveLength, 1)
+		So(ac.Problems(), ShouldBeEmpty)
+		So(ac.ToTextSummary(), ShouldEndWith, "3 servers have serial 2018010101;\n"+
+			"ns1.example.test (192.0.2.1)\nns1.example.test (2001:db8::1)\nns2.example.test (192.0.2.2)\n\n"+
+			"All of the servers agree\n")
+	})
+
+	Convey("the baseline is the answer most servers agree on", t, func() {
+		ac.Servers[0].TTL, ac.Servers[1].TTL, ac.Servers[2].TTL = 300, 600, 300
+		answer, ttl, err := ac.Baseline()
+		So(err, ShouldBeNil)
+		So(answer, ShouldEqual, "192.0.2.80")
+		So(ttl, ShouldEqual, 600)
+	})
+
+	Convey("lame servers, serial and answer mismatches are problems", t, func() {
+		ac.Servers[1].Lame = "REFUSED"
+		ac.Servers[2].Serial = 2018010102
+		ac.Query.Results[ns2.String()].Answer = "192.0.2.81"
+
+		problems := ac.Problems()
+		So(problems, ShouldResemble, []string{
+			"lame delegation to ns1.example.test (2001:db8::1): REFUSED",
+			"servers have different serials: 2018010101, 2018010102",
+			"servers gave 2 different answers",
+		})
+		So(ac.ToTextSummary(), ShouldContainSubstring, "\nAnd here are the problems;\n\nlame delegation")
+
+		text, err := ac.ToJSON()
+		So(err, ShouldBeNil)
+		So(text, ShouldContainSubstring, `"Zone":"example.test.","Domain":"www.example.test","Type":"A"`)
+		So(text, ShouldContainSubstring, `"Lame":"REFUSED"`)
+
+		answer, ttl, err := ac.Baseline()
+		So(err, ShouldBeNil)
+		So(answer, ShouldEqual, "192.0.2.80")
+		So(ttl, ShouldEqual, 300)
+		So(ac.Answers(), ShouldResemble, map[string]uint32{"192.0.2.80": 300, "192.0.2.81": 300})
+	})
+
+	Convey("an alias is the baseline if the servers answer with a CNAME", t, func() {
+		alias := &AuthCheck{
+			Zone:    "example.test.",
+			Servers: []AuthServer{ns1, ns2},
+			Query: &Query{Domain: "www.example.test", Type: dns.TypeA, Results: QueryResults{
+				ns1.String(): &Result{Answer: "cdn.example.net.", Chain: []string{"www.example.test.", "cdn.example.net."}},
+				ns2.String(): &Result{Answer: "cdn.example.net.", Chain: []string{"www.example.test.", "cdn.example.net."}},
+			}},
+		}
+		alias.Servers[0].TTL, alias.Servers[1].TTL = 300, 300
+
+		answer, ttl, err := alias.Baseline()
+		So(err, ShouldBeNil)
+		So(answer, ShouldEqual, "CNAME cdn.example.net.")
+		So(ttl, ShouldEqual, 300)
+	})
+
+	Convey("servers that could not be reached from here are not checked rather than lame", t, func() {
+		unreachable := &AuthCheck{
+			Zone:    "example.test.",
+			Servers: []AuthServer{ns1, {Name: ns1v6.Name, IP: ns1v6.IP, NotChecked: "UNREACHABLE"}},
+			Query: &Query{Domain: "www.example.test", Type: dns.TypeA, Results: QueryResults{
+				ns1.String(): &Result{Answer: "192.0.2.80"},
+			}},
+		}
+
+		So(unreachable.Problems(), ShouldBeEmpty)
+		So(unreachable.Serials(), ShouldResemble, map[uint32][]string{2018010101: {ns1.String()}})
+		So(unreachable.ToTextSummary(), ShouldContainSubstring, "1 servers could not be reached from here and were "+
+			"not checked;\nns1.example.test (2001:db8::1): UNREACHABLE\n\nAll of the servers agree\n")
+	})
+
+	Convey("only network errors from here are not checked", t, func() {
+		So(isLocalNetworkError(ErrUnreachable), ShouldBeTrue)
+		So(isLocalNetworkError(ErrNetwork), ShouldBeTrue)
+		So(isLocalNetworkError(ErrTimeout), ShouldBeFalse)
+		So(isLocalNetworkError(RcodeError(dns.RcodeRefused)), ShouldBeFalse)
+		So(isLocalNetworkError(nil), ShouldBeFalse)
+	})
+
+	Convey("there is no baseline if every server is lame", t, func() {
+		for i := range ac.Servers {
+			ac.Servers[i].Lame = "TIMEOUT"
+		}
+		_, _, err := ac.Baseline()
+		So(err, ShouldBeError)
+	})
+}
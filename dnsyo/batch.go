@@ -0,0 +1,216 @@
+package dnsyo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Batch is a set of queries for several names and record types that are run together against the same servers,
+// such as every name affected by a change.
+type Batch struct {
+	Queries []*Query
+}
+
+// BatchRow summarises the results of one of the queries in a batch.
+type BatchRow struct {
+	Domain   string
+	Type     string
+	Servers  int
+	Answered int
+	Errors   int
+
+	// Answer is the most common answer or error, and Agreement the percentage of servers that gave it
+	Answer    string
+	Agreement float64
+}
+
+// NewBatch creates a query for every combination of the domains and record types, copying the other options from
+// the template query.
+func NewBatch(template Query, domains []string, types []string) (*Batch, error) {
+	b := &Batch{}
+	for _, domain := range domains {
+		for _, t := range types {
+			if err := b.Add(template, domain, t); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+// Add adds a query for the domain and record type to the batch, copying the other options from the template query.
+// Queries already in the batch are not added again.
+func (b *Batch) Add(template Query, domain, recordType string) error {
+	q := template
+	q.Results = nil
+	if err := q.SetDomain(domain); err != nil {
+		return err
+	}
+	if err := q.SetType(recordType); err != nil {
+		return err
+	}
+	b.add(&q)
+	return nil
+}
+
+// AddReverse adds a query for the PTR record of an IP address to the batch, copying the other options from the
+// template query.
+func (b *Batch) AddReverse(template Query, ip string) error {
+	q := template
+	q.Results = nil
+	if err := q.SetReverse(ip); err != nil {
+		return err
+	}
+	b.add(&q)
+	return nil
+}
+
+// add adds a query to the batch unless there is already one for the same domain and type.
+func (b *Batch) add(q *Query) {
+	for _, existing := range b.Queries {
+		if strings.EqualFold(existing.Domain, q.Domain) && existing.Type == q.Type {
+			return
+		}
+	}
+	b.Queries = append(b.Queries, q)
+}
+
+// ReadQueries adds a query for each line of "name type" read from r. The type is optional and defaults to the
+// template's type; blank lines and lines starting with # are ignored.
+func (b *Batch) ReadQueries(r io.Reader, template Query) error {
+	scanner := bufio.NewScanner(r)
+	var line int
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		recordType := template.GetType()
+		switch len(fields) {
+		case 1:
+		case 2:
+			recordType = fields[1]
+		default:
+			return fmt.Errorf("line %d: expected a name and optional type, got %q", line, scanner.Text())
+		}
+
+		if err := b.Add(template, fields[0], recordType); err != nil {
+			return fmt.Errorf("line %d: %s", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// ReadQueriesFile adds the queries listed in a file, see ReadQueries.
+func (b *Batch) ReadQueriesFile(filename string, template Query) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return b.ReadQueries(f, template)
+}
+
+// ExecuteBatch runs every query in the batch against each server using a single pool of threads, setting the
+// results of each query.
+func (sl *ServerList) ExecuteBatch(b *Batch, threads int) {
+	var mtx sync.Mutex
+
+	for _, q := range b.Queries {
+		q.Results = make(QueryResults)
+	}
+	keys := sl.resultKeys()
+	probes := newValidationProbes()
+
+	// each server is sent every query in turn
+	runParallel(len(*sl)*len(b.Queries), threads, func(i int) {
+		s, q := (*sl)[i/len(b.Queries)], b.Queries[i%len(b.Queries)]
+		r := q.result(s, probes)
+
+		mtx.Lock()
+		q.Results[keys[i/len(b.Queries)]] = r
+		mtx.Unlock()
+	})
+}
+
+// Rows summarises each query in the batch in the order they were added.
+func (b *Batch) Rows() (rows []BatchRow) {
+	for _, q := range b.Queries {
+		row := BatchRow{
+			Domain:  q.DisplayDomain(),
+			Type:    q.GetType(),
+			Servers: len(q.Results),
+		}
+
+		counts := make(map[string]int)
+		for _, r := range q.Results {
+			if r.Error == "" && r.Answer != "" {
+				row.Answered++
+			} else {
+				row.Errors++
+			}
+			counts[resultText(r)]++
+		}
+
+		for answer, count := range counts {
+			if count > counts[row.Answer] || (count == counts[row.Answer] && answer < row.Answer) {
+				row.Answer = answer
+			}
+		}
+		if row.Servers > 0 {
+			row.Agreement = float64(counts[row.Answer]) / float64(row.Servers) * 100
+		}
+
+		rows = append(rows, row)
+	}
+	return
+}
+
+// ToTextSummary prints the summary of each query in the batch, followed by a table of the most common answer to
+// each query and how many servers agree on it.
+func (b *Batch) ToTextSummary() (text string) {
+	for _, q := range b.Queries {
+		text += q.ToTextSummary()
+	}
+
+	text += fmt.Sprintf("\n - ALL QUERIES\nI made %d queries, here is the most common result for each;\n\n", len(b.Queries))
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tANSWERED\tERRORS\tAGREE\tRESULT")
+	for _, row := range b.Rows() {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.0f%%\t%s\n",
+			row.Domain, row.Type, row.Answered, row.Errors, row.Agreement, row.Answer)
+	}
+	w.Flush()
+	text += buf.String()
+
+	return text
+}
+
+// ToJSON prints the results of each query along with the summary rows.
+func (b *Batch) ToJSON() (string, error) {
+	var queries []json.RawMessage
+	for _, q := range b.Queries {
+		text, err := q.ToJSON()
+		if err != nil {
+			return "", err
+		}
+		queries = append(queries, json.RawMessage(text))
+	}
+
+	text, err := json.Marshal(struct {
+		Queries []json.RawMessage
+		Summary []BatchRow
+	}{queries, b.Rows()})
+	return string(text), err
+}
@@ -0,0 +1,83 @@
+package dnsyo
+
+import (
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+func TestNewBatch(t *testing.T) {
+	Convey("a query is made for every name and type", t, func() {
+		b, err := NewBatch(Query{DNSSEC: true}, []string{"example.com", "example.net"}, []string{"A", "aaaa"})
+		So(err, ShouldBeNil)
+		So(b.Queries, ShouldHaveLength, 4)
+		So(b.Queries[1].Domain, ShouldEqual, "example.com")
+		So(b.Queries[1].Type, ShouldEqual, dns.TypeAAAA)
+		So(b.Queries[3].DNSSEC, ShouldBeTrue)
+	})
+
+	Convey("unknown types are an error", t, func() {
+		_, err := NewBatch(Query{}, []string{"example.com"}, []string{"NOPE"})
+		So(err, ShouldBeError)
+	})
+}
+
+func TestBatch_ReadQueries(t *testing.T) {
+	template := Query{Type: dns.TypeA}
+
+	Convey("names are read with an optional type", t, func() {
+		b := &Batch{}
+		err := b.ReadQueries(strings.NewReader("# release 42\nexample.com\n\nexample.com MX\nEXAMPLE.COM a\n"), template)
+		So(err, ShouldBeNil)
+		So(b.Queries, ShouldHaveLength, 2)
+		So(b.Queries[0].Type, ShouldEqual, dns.TypeA)
+		So(b.Queries[1].Type, ShouldEqual, dns.TypeMX)
+	})
+
+	Convey("errors give the line number", t, func() {
+		b := &Batch{}
+		err := b.ReadQueries(strings.NewReader("example.com\nexample.com A extra\n"), template)
+		So(err, ShouldBeError, `line 2: expected a name and optional type, got "example.com A extra"`)
+
+		err = b.ReadQueries(strings.NewReader("example.com NOPE\n"), template)
+		So(err, ShouldBeError, "line 1: unable to use record type NOPE")
+	})
+}
+
+func TestBatch_ToTextSummary(t *testing.T) {
+	b, _ := NewBatch(Query{}, []string{"example.com"}, []string{"A", "MX"})
+	b.Queries[0].Results = QueryResults{
+		"a": &Result{Answer: "192.0.2.1"},
+		"b": &Result{Answer: "192.0.2.1"},
+		"c": &Result{Answer: "192.0.2.2"},
+		"d": &Result{Error: "TIMEOUT"},
+	}
+	b.Queries[1].Results = QueryResults{
+		"a": &Result{Error: "NOANSWER"},
+	}
+
+	Convey("each query is summarised in a row", t, func() {
+		rows := b.Rows()
+		So(rows, ShouldHaveLength, 2)
+		So(rows[0], ShouldResemble, BatchRow{
+			Domain: "example.com", Type: "A", Servers: 4, Answered: 3, Errors: 1, Answer: "192.0.2.1", Agreement: 50,
+		})
+		So(rows[1].Answer, ShouldEqual, "NOANSWER")
+		So(rows[1].Agreement, ShouldEqual, 100)
+	})
+
+	Convey("the text summary has a section for each query and the table", t, func() {
+		text := b.ToTextSummary()
+		So(strings.Count(text, " - RESULTS"), ShouldEqual, 2)
+		So(text, ShouldContainSubstring, " - ALL QUERIES\nI made 2 queries")
+		So(text, ShouldContainSubstring, "example.com  A     3         1       50%    192.0.2.1\n")
+	})
+
+	Convey("the JSON contains every query and the summary", t, func() {
+		text, err := b.ToJSON()
+		So(err, ShouldBeNil)
+		So(text, ShouldStartWith, `{"Queries":[{"Domain":"example.com","Type":"A",`)
+		So(text, ShouldContainSubstring, `"Summary":[{"Domain":"example.com","Type":"A","Servers":4`)
+	})
+}
@@ -0,0 +1,367 @@
+package dnsyo
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/miekg/dns"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Whether a CA may issue a certificate according to a server's view of the CAA records
+const (
+	CAAPermitted = "permitted"
+	CAAForbidden = "forbidden"
+	CAAUnknown   = "unknown"
+)
+
+// CAARecord is a parsed CAA record.
+type CAARecord struct {
+	Flag  uint8
+	Tag   string
+	Value string
+}
+
+// CAAView is the set of CAA records that applies to the domain from one server, found by climbing the tree from the
+// domain towards the root as in RFC 8659 section 3.
+type CAAView struct {
+	// Name is where the records were found, empty if there are none
+	Name    string      `json:",omitempty"`
+	Records []CAARecord `json:",omitempty"`
+
+	// Error is set if the lookup failed, when a CA must not issue
+	Error string `json:",omitempty"`
+
+	// Status is whether the CA being checked may issue
+	Status string
+}
+
+// TLSARecord is a parsed TLSA record.
+type TLSARecord struct {
+	Usage        uint8
+	Selector     uint8
+	MatchingType uint8
+	Data         string
+
+	Problems []string `json:",omitempty"`
+}
+
+// CertCheck checks what a CA's resolvers will see before a certificate is issued: whether the CAA records allow the CA
+// to issue, and the TLSA records that will need to match the certificate.
+type CertCheck struct {
+	Domain string
+
+	// Issuer is the CA's identifying domain, such as letsencrypt.org, and Wildcard is set when checking a wildcard
+	// certificate, which uses the issuewild property if there is one
+	Issuer   string
+	Wildcard bool
+
+	// CAA is each server's view of the CAA records
+	CAA map[string]*CAAView
+
+	// TLSA is the query for the TLSA records of the service, if one was checked
+	TLSA *Query
+}
+
+// NewCertCheck creates a check of the CAA records of a domain for an issuer. If port is not 0 the TLSA records of the
+// service on that port are checked too.
+func NewCertCheck(domain, issuer string, wildcard bool, port int, proto string) (*CertCheck, error) {
+	q := &Query{}
+	if err := q.SetDomain(strings.TrimPrefix(domain, "*.")); err != nil {
+		return nil, err
+	}
+
+	c := &CertCheck{
+		Domain:   dns.Fqdn(q.Domain),
+		Issuer:   strings.ToLower(strings.TrimSuffix(issuer, ".")),
+		Wildcard: wildcard || strings.HasPrefix(domain, "*."),
+	}
+
+	if port != 0 {
+		if port < 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+		c.TLSA = &Query{Type: dns.TypeTLSA}
+		if err := c.TLSA.SetDomain(fmt.Sprintf("_%d._%s.%s", port, proto, c.Domain)); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// ExecuteCertCheck finds each server's view of the CAA records, then queries the TLSA records if they are checked.
+func (sl *ServerList) ExecuteCertCheck(c *CertCheck, threads int) {
+	c.CAA = make(map[string]*CAAView)
+	keys := sl.resultKeys()
+	var mtx sync.Mutex
+
+	runParallel(len(*sl), threads, func(i int) {
+		s := (*sl)[i]
+		view := s.findCAA(c.Domain)
+		view.Status = c.permitted(view)
+
+		mtx.Lock()
+		c.CAA[keys[i]] = view
+		mtx.Unlock()
+	})
+
+	if c.TLSA != nil {
+		c.TLSA.Results = sl.ExecuteQuery(c.TLSA, threads)
+	}
+}
+
+// findCAA climbs from the domain towards the root until the server returns CAA records. The search stops if the
+// server fails, other than with an empty answer or NXDOMAIN, as the records can't be known.
+func (s *Server) findCAA(domain string) *CAAView {
+	labels := dns.SplitDomainName(domain)
+	for i := range labels {
+		name := dns.Fqdn(strings.Join(labels[i:], "."))
+		resp, _, err := s.send(newQuestion(name, dns.TypeCAA, true))
+		if err != nil {
+			le := classifyError(err)
+			if le.Is(ErrNoAnswer) || le.Is(RcodeError(dns.RcodeNameError)) {
+				continue
+			}
+			return &CAAView{Error: le.Error()}
+		}
+
+		view := &CAAView{Name: name, Records: caaRecords(resp)}
+		if len(view.Records) > 0 {
+			return view
+		}
+	}
+	return &CAAView{}
+}
+
+// caaRecords parses the CAA records in a response.
+func caaRecords(resp *dns.Msg) (records []CAARecord) {
+	for _, rr := range resp.Answer {
+		if caa, ok := rr.(*dns.CAA); ok {
+			records = append(records, CAARecord{caa.Flag, strings.ToLower(caa.Tag), caa.Value})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].String() < records[j].String()
+	})
+	return
+}
+
+// String gives the record in presentation format.
+func (r CAARecord) String() string {
+	return fmt.Sprintf("%d %s %q", r.Flag, r.Tag, r.Value)
+}
+
+// permitted decides whether the issuer may issue according to a view of the CAA records, from RFC 8659 section 4.
+func (c *CertCheck) permitted(view *CAAView) string {
+	if view.Error != "" {
+		return CAAUnknown
+	}
+
+	var issue, issuewild []string
+	for _, r := range view.Records {
+		switch r.Tag {
+		case "issue":
+			issue = append(issue, r.Value)
+		case "issuewild":
+			issuewild = append(issuewild, r.Value)
+		case "iodef", "contactemail", "contactphone", "issuemail", "issuevmc":
+		default:
+			// the CA must not issue if a property it does not understand is marked critical
+			if r.Flag&128 != 0 {
+				return CAAForbidden
+			}
+		}
+	}
+
+	// wildcard certificates use issuewild if there is one, and the records only restrict issuance if they have any
+	values := issue
+	if c.Wildcard && len(issuewild) > 0 {
+		values = issuewild
+	}
+	if len(values) == 0 {
+		return CAAPermitted
+	}
+
+	for _, value := range values {
+		issuer := strings.TrimSpace(strings.SplitN(value, ";", 2)[0])
+		if strings.EqualFold(strings.TrimSuffix(issuer, "."), c.Issuer) {
+			return CAAPermitted
+		}
+	}
+	return CAAForbidden
+}
+
+// ParseTLSA parses a TLSA record from the answer of a result and checks its fields, from RFC 6698 section 2.1.
+func ParseTLSA(answer string) (*TLSARecord, error) {
+	fields := strings.Fields(answer)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("invalid TLSA record %s", answer)
+	}
+
+	var numbers [3]uint8
+	for i := range numbers {
+		n, err := strconv.ParseUint(fields[i], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLSA record %s", answer)
+		}
+		numbers[i] = uint8(n)
+	}
+
+	t := &TLSARecord{Usage: numbers[0], Selector: numbers[1], MatchingType: numbers[2], Data: strings.ToLower(fields[3])}
+	if t.Usage > 3 {
+		t.Problems = append(t.Problems, fmt.Sprintf("unknown certificate usage %d", t.Usage))
+	}
+	if t.Selector > 1 {
+		t.Problems = append(t.Problems, fmt.Sprintf("unknown selector %d", t.Selector))
+	}
+	lengths := map[uint8]int{1: 64, 2: 128}
+	if t.MatchingType > 2 {
+		t.Problems = append(t.Problems, fmt.Sprintf("unknown matching type %d", t.MatchingType))
+	} else if l, ok := lengths[t.MatchingType]; ok && len(t.Data) != l {
+		t.Problems = append(t.Problems, fmt.Sprintf("the hash is %d hex digits, expected %d", len(t.Data), l))
+	}
+	return t, nil
+}
+
+// String describes the record, such as "DANE-EE SPKI SHA2-256".
+func (t *TLSARecord) String() string {
+	usages := []string{"PKIX-TA", "PKIX-EE", "DANE-TA", "DANE-EE"}
+	selectors := []string{"Cert", "SPKI"}
+	matching := []string{"Full", "SHA2-256", "SHA2-512"}
+
+	name := func(names []string, n uint8) string {
+		if int(n) < len(names) {
+			return names[n]
+		}
+		return strconv.Itoa(int(n))
+	}
+	return fmt.Sprintf("%s %s %s", name(usages, t.Usage), name(selectors, t.Selector), name(matching, t.MatchingType))
+}
+
+// caaText is the text used to group servers with the same view of the CAA records.
+func (v *CAAView) caaText() string {
+	if v.Error != "" {
+		return v.Error
+	}
+	if v.Name == "" {
+		return "no CAA records"
+	}
+
+	var records []string
+	for _, r := range v.Records {
+		records = append(records, r.String())
+	}
+	return fmt.Sprintf("%s\n%s", v.Name, strings.Join(records, "\n"))
+}
+
+// Forbidden counts the servers whose view of the CAA records does not allow the issuer, or could not be checked.
+func (c *CertCheck) Forbidden() (forbidden int) {
+	for _, v := range c.CAA {
+		if v.Status != CAAPermitted {
+			forbidden++
+		}
+	}
+	return
+}
+
+// ToTextSummary prints the servers grouped by their view of the CAA records and whether it allows the issuer,
+// followed by the summary of the TLSA query.
+func (c *CertCheck) ToTextSummary() (text string) {
+	statuses := make(map[string]int)
+	views := make(map[string]int)
+	for _, v := range c.CAA {
+		statuses[v.Status]++
+		views[v.caaText()+"\n"+v.Status]++
+	}
+
+	certificate := "a certificate"
+	if c.Wildcard {
+		certificate = "a wildcard certificate"
+	}
+	text = fmt.Sprintf(`
+ - CAA
+I asked %d servers whether %s may issue %s for %s,
+%d permit it, %d forbid it and %d could not be checked
+Here are the CAA records they see;`, len(c.CAA), c.Issuer, certificate, c.Domain,
+		statuses[CAAPermitted], statuses[CAAForbidden], statuses[CAAUnknown])
+	text += "\n\n\n"
+
+	var keys []string
+	for key := range views {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if views[keys[i]] != views[keys[j]] {
+			return views[keys[i]] > views[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys {
+		text += fmt.Sprintf("%d servers responded with;\n%s\n\n", views[key], key)
+	}
+
+	if c.TLSA != nil {
+		text += c.TLSA.ToTextSummary()
+		text += c.tlsaTextSummary()
+	}
+	return text
+}
+
+// tlsaTextSummary describes each TLSA record the servers returned and any problems with it.
+func (c *CertCheck) tlsaTextSummary() (text string) {
+	seen := make(map[string]bool)
+	var records []string
+	for _, r := range c.TLSA.Results {
+		if r.Error != "" {
+			continue
+		}
+		for _, answer := range strings.Split(r.Answer, "\n") {
+			if !seen[answer] {
+				seen[answer] = true
+				records = append(records, answer)
+			}
+		}
+	}
+	if len(records) == 0 {
+		return ""
+	}
+	sort.Strings(records)
+
+	text = fmt.Sprint("\nAnd here is what the TLSA records match;\n\n")
+	for _, answer := range records {
+		t, err := ParseTLSA(answer)
+		if err != nil {
+			text += fmt.Sprintf("%s\n\n", err)
+			continue
+		}
+		text += fmt.Sprintf("%s\n%s\n", answer, t)
+		for _, problem := range t.Problems {
+			text += fmt.Sprintf("problem: %s\n", problem)
+		}
+		text += "\n"
+	}
+	return text
+}
+
+// ToJSON prints each server's view of the CAA records and the TLSA query as JSON.
+func (c *CertCheck) ToJSON() (string, error) {
+	var tlsa interface{}
+	if c.TLSA != nil {
+		text, err := c.TLSA.ToJSON()
+		if err != nil {
+			return "", err
+		}
+		tlsa = json.RawMessage(text)
+	}
+
+	text, err := json.Marshal(struct {
+		Domain   string
+		Issuer   string
+		Wildcard bool `json:",omitempty"`
+		CAA      map[string]*CAAView
+		TLSA     interface{} `json:",omitempty"`
+	}{c.Domain, c.Issuer, c.Wildcard, c.CAA, tlsa})
+	return string(text), err
+}
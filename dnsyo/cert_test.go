@@ -0,0 +1,115 @@
+package dnsyo
+
+import (
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+func TestNewCertCheck(t *testing.T) {
+	Convey("wildcard names are checked as wildcards", t, func() {
+		c, err := NewCertCheck("*.example.com", "LetsEncrypt.org.", false, 443, "tcp")
+		So(err, ShouldBeNil)
+		So(c.Domain, ShouldEqual, "example.com.")
+		So(c.Issuer, ShouldEqual, "letsencrypt.org")
+		So(c.Wildcard, ShouldBeTrue)
+		So(c.TLSA.Domain, ShouldEqual, "_443._tcp.example.com.")
+		So(c.TLSA.Type, ShouldEqual, dns.TypeTLSA)
+	})
+
+	Convey("TLSA is only checked with a port", t, func() {
+		c, err := NewCertCheck("example.com", "letsencrypt.org", false, 0, "tcp")
+		So(err, ShouldBeNil)
+		So(c.TLSA, ShouldBeNil)
+
+		_, err = NewCertCheck("example.com", "letsencrypt.org", false, 70000, "tcp")
+		So(err, ShouldBeError, "invalid port 70000")
+	})
+}
+
+func TestCertCheck_permitted(t *testing.T) {
+	c := &CertCheck{Issuer: "letsencrypt.org"}
+	view := func(records ...CAARecord) *CAAView {
+		return &CAAView{Name: "example.com.", Records: records}
+	}
+
+	Convey("issuance is permitted without any CAA records", t, func() {
+		So(c.permitted(&CAAView{}), ShouldEqual, CAAPermitted)
+		So(c.permitted(view(CAARecord{0, "iodef", "mailto:security@example.com"})), ShouldEqual, CAAPermitted)
+	})
+
+	Convey("the issuer must be listed in an issue property", t, func() {
+		So(c.permitted(view(CAARecord{0, "issue", "letsencrypt.org; validationmethods=dns-01"})), ShouldEqual, CAAPermitted)
+		So(c.permitted(view(CAARecord{0, "issue", "pki.goog"})), ShouldEqual, CAAForbidden)
+		So(c.permitted(view(CAARecord{0, "issue", ";"})), ShouldEqual, CAAForbidden)
+	})
+
+	Convey("wildcards use issuewild if there is one", t, func() {
+		wild := &CertCheck{Issuer: "letsencrypt.org", Wildcard: true}
+		v := view(CAARecord{0, "issue", "letsencrypt.org"}, CAARecord{0, "issuewild", "pki.goog"})
+		So(wild.permitted(v), ShouldEqual, CAAForbidden)
+		So(c.permitted(v), ShouldEqual, CAAPermitted)
+		So(c.permitted(view(CAARecord{0, "issuewild", "pki.goog"})), ShouldEqual, CAAPermitted)
+	})
+
+	Convey("unknown critical properties forbid issuance", t, func() {
+		So(c.permitted(view(CAARecord{128, "future", "x"}, CAARecord{0, "issue", "letsencrypt.org"})), ShouldEqual, CAAForbidden)
+	})
+
+	Convey("lookup failures can't be checked", t, func() {
+		So(c.permitted(&CAAView{Error: "SERVFAIL"}), ShouldEqual, CAAUnknown)
+	})
+}
+
+func TestParseTLSA(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+
+	Convey("TLSA records are parsed and described", t, func() {
+		tlsa, err := ParseTLSA("3 1 1 " + strings.ToUpper(hash))
+		So(err, ShouldBeNil)
+		So(tlsa, ShouldResemble, &TLSARecord{Usage: 3, Selector: 1, MatchingType: 1, Data: hash})
+		So(tlsa.String(), ShouldEqual, "DANE-EE SPKI SHA2-256")
+	})
+
+	Convey("invalid fields are problems", t, func() {
+		tlsa, err := ParseTLSA("4 1 2 " + hash)
+		So(err, ShouldBeNil)
+		So(tlsa.Problems, ShouldResemble, []string{
+			"unknown certificate usage 4",
+			"the hash is 64 hex digits, expected 128",
+		})
+
+		_, err = ParseTLSA("3 1")
+		So(err, ShouldBeError, "invalid TLSA record 3 1")
+	})
+}
+
+func TestCertCheck_ToTextSummary(t *testing.T) {
+	c, _ := NewCertCheck("www.example.com", "letsencrypt.org", false, 443, "tcp")
+	c.CAA = map[string]*CAAView{
+		"a": {Name: "example.com.", Records: []CAARecord{{0, "issue", "letsencrypt.org"}}, Status: CAAPermitted},
+		"b": {Name: "example.com.", Records: []CAARecord{{0, "issue", "letsencrypt.org"}}, Status: CAAPermitted},
+		"c": {Name: "example.com.", Records: []CAARecord{{0, "issue", "pki.goog"}}, Status: CAAForbidden},
+		"d": {Error: "TIMEOUT", Status: CAAUnknown},
+	}
+	c.TLSA.Type = dns.TypeTLSA
+	c.TLSA.Results = QueryResults{"a": &Result{Answer: "3 1 1 " + strings.Repeat("ab", 32)}}
+
+	Convey("servers are grouped by the records they see", t, func() {
+		text := c.ToTextSummary()
+		So(text, ShouldContainSubstring, "I asked 4 servers whether letsencrypt.org may issue a certificate for "+
+			"www.example.com.,\n2 permit it, 1 forbid it and 1 could not be checked\n")
+		So(text, ShouldContainSubstring, "2 servers responded with;\nexample.com.\n0 issue \"letsencrypt.org\"\npermitted\n\n")
+		So(text, ShouldContainSubstring, "1 servers responded with;\nTIMEOUT\nunknown\n\n")
+		So(text, ShouldContainSubstring, "\nAnd here is what the TLSA records match;\n\n3 1 1 abab")
+		So(c.Forbidden(), ShouldEqual, 2)
+	})
+
+	Convey("the JSON includes the TLSA query", t, func() {
+		text, err := c.ToJSON()
+		So(err, ShouldBeNil)
+		So(text, ShouldContainSubstring, `"d":{"Error":"TIMEOUT","Status":"unknown"}`)
+		So(text, ShouldContainSubstring, `"TLSA":{"Domain":"_443._tcp.www.example.com."`)
+	})
+}
@@ -0,0 +1,148 @@
+package dnsyo
+
+import (
+	"fmt"
+	"github.com/miekg/dns"
+	"sort"
+	"strings"
+)
+
+// Chains groups the servers by the CNAME chain they followed to reach the answer, so that servers that resolved
+// through a different alias stand out.
+type Chains struct {
+	// Majority is the chain most servers followed, and Servers the servers that followed each chain
+	Majority string
+	Servers  map[string][]string
+
+	// Differs lists the servers that followed a chain other than the majority
+	Differs []string `json:",omitempty"`
+}
+
+// cnameChain follows the CNAME records in a response from the question name. It returns each name in the chain and
+// the records for the last of them, or a nil chain if the answer has no CNAME for the question.
+func cnameChain(resp *dns.Msg) (chain []string, final []dns.RR) {
+	if len(resp.Question) == 0 || resp.Question[0].Qtype == dns.TypeCNAME {
+		return nil, nil
+	}
+
+	name := resp.Question[0].Name
+	chain = []string{name}
+	// the chain cannot be longer than the answer, which stops a loop of aliases being followed forever
+	for range resp.Answer {
+		target := ""
+		for _, rr := range resp.Answer {
+			if cname, ok := rr.(*dns.CNAME); ok && strings.EqualFold(cname.Hdr.Name, name) {
+				target = cname.Target
+				break
+			}
+		}
+		if target == "" {
+			break
+		}
+		name = target
+		chain = append(chain, name)
+	}
+
+	if len(chain) == 1 {
+		return nil, nil
+	}
+
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype != dns.TypeCNAME && strings.EqualFold(rr.Header().Name, name) {
+			final = append(final, rr)
+		}
+	}
+	return chain, final
+}
+
+// aliasAnswer is the answer used to compare results by the first target of their CNAME chain, see Query.CompareAlias.
+// Returns false if the result did not follow an alias.
+func aliasAnswer(r *Result) (string, bool) {
+	if len(r.Chain) < 2 {
+		return "", false
+	}
+	return "CNAME " + r.Chain[1], true
+}
+
+// chainText shows the path a result took to its answer, such as "www.example.com. -> cdn.example.net. -> A", or to
+// the error the target gave, such as "www.example.com. -> cdn.example.net. -> NXDOMAIN". Returns an empty string if
+// there were no aliases.
+func (q *Query) chainText(r *Result) string {
+	if len(r.Chain) == 0 {
+		return ""
+	}
+	text := strings.Join(r.Chain, " -> ")
+	switch {
+	case r.Error != "":
+		text += " -> " + r.Error
+	case r.Answer != "":
+		text += " -> " + q.GetType()
+	}
+	return text
+}
+
+// Chains groups the results by the CNAME chain each server followed, or returns nil if no server followed one.
+// Servers that gave an answer without any aliases are grouped under "none".
+func (q *Query) Chains() *Chains {
+	c := &Chains{Servers: make(map[string][]string)}
+	var aliased bool
+
+	for name, r := range q.Results {
+		if r.Error != "" && len(r.Chain) == 0 {
+			continue
+		}
+		chain := q.chainText(r)
+		if chain == "" {
+			chain = "none"
+		} else {
+			aliased = true
+		}
+		c.Servers[chain] = append(c.Servers[chain], name)
+	}
+
+	if !aliased {
+		return nil
+	}
+
+	for chain, servers := range c.Servers {
+		sort.Strings(servers)
+		if c.Majority == "" || len(servers) > len(c.Servers[c.Majority]) ||
+			len(servers) == len(c.Servers[c.Majority]) && chain < c.Majority {
+			c.Majority = chain
+		}
+	}
+
+	for chain, servers := range c.Servers {
+		if chain != c.Majority {
+			c.Differs = append(c.Differs, servers...)
+		}
+	}
+	sort.Strings(c.Differs)
+
+	return c
+}
+
+// chainTextSummary produces the CNAME section of Query.ToTextSummary, listing the servers that followed a different
+// chain to the majority, or an empty string if they all agree.
+func (q *Query) chainTextSummary() (text string) {
+	c := q.Chains()
+	if c == nil || len(c.Differs) == 0 {
+		return ""
+	}
+
+	var chains []string
+	for chain := range c.Servers {
+		if chain != c.Majority {
+			chains = append(chains, chain)
+		}
+	}
+	sort.Strings(chains)
+
+	text = fmt.Sprint("\nAnd here are the servers that followed a different CNAME chain;\n\n")
+	text += fmt.Sprintf("%d servers followed;\n%s\n\n", len(c.Servers[c.Majority]), c.Majority)
+	for _, chain := range chains {
+		servers := c.Servers[chain]
+		text += fmt.Sprintf("%d servers followed;\n%s\n%s\n\n", len(servers), chain, strings.Join(servers, ", "))
+	}
+	return text
+}
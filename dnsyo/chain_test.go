@@ -0,0 +1,111 @@
+package dnsyo
+
+import (
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func chainResponse(qtype uint16, records ...string) *dns.Msg {
+	resp := new(dns.Msg)
+	resp.SetQuestion("www.example.com.", qtype)
+	for _, record := range records {
+		rr, _ := dns.NewRR(record)
+		resp.Answer = append(resp.Answer, rr)
+	}
+	return resp
+}
+
+func TestCnameChain(t *testing.T) {
+	Convey("aliases are followed to the final records", t, func() {
+		resp := chainResponse(dns.TypeA,
+			"www.example.com. 300 IN CNAME cdn.example.net.",
+			"cdn.example.net. 60 IN CNAME edge.example.org.",
+			"edge.example.org. 20 IN A 192.0.2.1",
+			"edge.example.org. 20 IN A 192.0.2.2",
+		)
+		chain, final := cnameChain(resp)
+		So(chain, ShouldResemble, []string{"www.example.com.", "cdn.example.net.", "edge.example.org."})
+		So(final, ShouldHaveLength, 2)
+
+		r := newResult(Server{}, resp, 0, nil)
+		So(r.Answer, ShouldEqual, "192.0.2.1\n192.0.2.2")
+		So(r.Chain, ShouldHaveLength, 3)
+	})
+
+	Convey("the chain is recorded for an alias to a name that does not exist", t, func() {
+		resp := chainResponse(dns.TypeA, "www.example.com. 300 IN CNAME gone.cdn.example.net.")
+		resp.Rcode = dns.RcodeNameError
+
+		r := newResult(Server{}, resp, 0, RcodeError(dns.RcodeNameError))
+		So(r.Error, ShouldEqual, "NXDOMAIN")
+		So(r.Answer, ShouldBeEmpty)
+		So(r.Chain, ShouldResemble, []string{"www.example.com.", "gone.cdn.example.net."})
+
+		q := &Query{Type: dns.TypeA, Results: QueryResults{
+			"a": r,
+			"b": &Result{Answer: "192.0.2.1", Chain: []string{"www.example.com.", "cdn.example.net."}},
+			"c": &Result{Answer: "192.0.2.1", Chain: []string{"www.example.com.", "cdn.example.net."}},
+		}}
+		c := q.Chains()
+		So(c.Differs, ShouldResemble, []string{"a"})
+		So(c.Servers, ShouldContainKey, "www.example.com. -> gone.cdn.example.net. -> NXDOMAIN")
+	})
+
+	Convey("answers without aliases have no chain", t, func() {
+		chain, _ := cnameChain(chainResponse(dns.TypeA, "www.example.com. 300 IN A 192.0.2.1"))
+		So(chain, ShouldBeNil)
+	})
+
+	Convey("CNAME queries are not followed", t, func() {
+		chain, _ := cnameChain(chainResponse(dns.TypeCNAME, "www.example.com. 300 IN CNAME cdn.example.net."))
+		So(chain, ShouldBeNil)
+	})
+
+	Convey("a loop of aliases stops", t, func() {
+		chain, final := cnameChain(chainResponse(dns.TypeA,
+			"www.example.com. 300 IN CNAME cdn.example.net.",
+			"cdn.example.net. 300 IN CNAME www.example.com.",
+		))
+		So(chain, ShouldHaveLength, 3)
+		So(final, ShouldBeEmpty)
+	})
+}
+
+func TestQuery_Chains(t *testing.T) {
+	q := &Query{
+		Type: dns.TypeA,
+		Results: QueryResults{
+			"a": &Result{Answer: "192.0.2.1", Chain: []string{"www.example.com.", "cdn.example.net."}},
+			"b": &Result{Answer: "192.0.2.1", Chain: []string{"www.example.com.", "cdn.example.net."}},
+			"c": &Result{Answer: "192.0.2.9", Chain: []string{"www.example.com.", "old.example.net."}},
+			"d": &Result{Error: "TIMEOUT"},
+		},
+	}
+
+	Convey("servers are grouped by the chain they followed", t, func() {
+		c := q.Chains()
+		So(c.Majority, ShouldEqual, "www.example.com. -> cdn.example.net. -> A")
+		So(c.Servers[c.Majority], ShouldResemble, []string{"a", "b"})
+		So(c.Differs, ShouldResemble, []string{"c"})
+	})
+
+	Convey("the summary flags the servers that differ", t, func() {
+		text := q.ToTextSummary()
+		So(text, ShouldContainSubstring, "\nAnd here are the servers that followed a different CNAME chain;\n\n"+
+			"2 servers followed;\nwww.example.com. -> cdn.example.net. -> A\n\n"+
+			"1 servers followed;\nwww.example.com. -> old.example.net. -> A\nc\n\n")
+	})
+
+	Convey("the summary can be grouped by chain", t, func() {
+		So(q.SetGroupBy([]string{"Chain"}), ShouldBeNil)
+		text := q.ToTextSummary()
+		So(text, ShouldContainSubstring, "1 servers responded with;\n192.0.2.9\nchain: www.example.com. -> old.example.net. -> A\n\n")
+		So(text, ShouldContainSubstring, "1 servers responded with;\nTIMEOUT\nchain: none\n\n")
+		q.GroupBy = nil
+	})
+
+	Convey("there are no chains if no server followed an alias", t, func() {
+		So((&Query{Results: QueryResults{"a": &Result{Answer: "192.0.2.1"}}}).Chains(), ShouldBeNil)
+	})
+}
@@ -0,0 +1,123 @@
+package dnsyo
+
+import (
+	"fmt"
+	"github.com/miekg/dns"
+	"sync"
+)
+
+// brokenDNSSECDomain is signed with deliberately broken signatures, so validating resolvers return SERVFAIL for it.
+const brokenDNSSECDomain = "dnssec-failed.org"
+
+// Validation statuses given to results in DNSSEC mode
+const (
+	ValidationValidating    = "validating"
+	ValidationNonValidating = "non-validating"
+	ValidationUnknown       = "unknown"
+)
+
+// dnssecQuestion creates a recursive question with the DO bit set so that signatures are returned, optionally with
+// checking disabled so that a validating resolver returns the answer even if it is bogus.
+func dnssecQuestion(name string, recordType uint16, checkingDisabled bool) *dns.Msg {
+	msg := newQuestion(name, recordType, true)
+	msg.SetEdns0(4096, true)
+	msg.CheckingDisabled = checkingDisabled
+	return msg
+}
+
+// checkValidation finds whether the server validates DNSSEC by asking for a domain with broken signatures.
+// A validating server returns SERVFAIL, but answers when checking is disabled; anything else is unknown.
+func (s *Server) checkValidation() string {
+	resp, _, _ := s.send(dnssecQuestion(brokenDNSSECDomain, dns.TypeA, false))
+	switch {
+	case resp == nil:
+		return ValidationUnknown
+
+	case resp.Rcode == dns.RcodeSuccess && len(resp.Answer) > 0:
+		return ValidationNonValidating
+
+	case resp.Rcode == dns.RcodeServerFailure:
+		if _, _, err := s.send(dnssecQuestion(brokenDNSSECDomain, dns.TypeA, true)); err == nil {
+			return ValidationValidating
+		}
+	}
+
+	return ValidationUnknown
+}
+
+// validationProbes remembers the outcome of checkValidation for each server, so that a server asked several queries in
+// the same run, such as a batch or one query per client subnet, is only probed once.
+type validationProbes struct {
+	mtx    sync.Mutex
+	probes map[string]*validationProbe
+}
+
+// validationProbe is the outcome of checkValidation for one server, found by the first query to need it.
+type validationProbe struct {
+	once       sync.Once
+	validation string
+}
+
+func newValidationProbes() *validationProbes {
+	return &validationProbes{probes: make(map[string]*validationProbe)}
+}
+
+// check returns whether the server validates DNSSEC, probing it if it has not been already.
+func (vp *validationProbes) check(s Server) string {
+	vp.mtx.Lock()
+	p, ok := vp.probes[s.IP]
+	if !ok {
+		p = &validationProbe{}
+		vp.probes[s.IP] = p
+	}
+	vp.mtx.Unlock()
+
+	p.once.Do(func() {
+		p.validation = s.checkValidation()
+	})
+	return p.validation
+}
+
+// hasSignatures checks if any of the answer records are signatures.
+func hasSignatures(resp *dns.Msg) bool {
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype == dns.TypeRRSIG {
+			return true
+		}
+	}
+	return false
+}
+
+// dnssecTextSummary produces the DNSSEC section of Query.ToTextSummary, or an empty string if DNSSEC was not checked.
+func (qr QueryResults) dnssecTextSummary() (text string) {
+	validation := make(map[string]int)
+	var ad, signed int
+
+	for _, r := range qr {
+		if r.Validation == "" {
+			continue
+		}
+		validation[r.Validation]++
+		if r.AD {
+			ad++
+		}
+		if r.RRSIG {
+			signed++
+		}
+	}
+
+	if len(validation) == 0 {
+		return ""
+	}
+
+	text = fmt.Sprint("\nAnd here is how they handle DNSSEC;\n\n")
+	text += fmt.Sprintf("%d servers validate signatures\n", validation[ValidationValidating])
+	text += fmt.Sprintf("%d servers do not validate signatures\n", validation[ValidationNonValidating])
+	if validation[ValidationUnknown] > 0 {
+		text += fmt.Sprintf("%d servers could not be checked\n", validation[ValidationUnknown])
+	}
+	text += fmt.Sprintf("%d servers set the authenticated data (AD) flag\n", ad)
+	text += fmt.Sprintf("%d servers returned signatures\n", signed)
+
+	return text
+}
@@ -0,0 +1,81 @@
+package dnsyo
+
+import (
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestQuery_Message(t *testing.T) {
+	q := &Query{Domain: "example.test", Type: dns.TypeA}
+
+	Convey("normal queries do not ask for signatures", t, func() {
+		m := q.message()
+		So(m.RecursionDesired, ShouldBeTrue)
+		So(m.IsEdns0(), ShouldBeNil)
+	})
+
+	Convey("DNSSEC queries set the DO bit without disabling checking", t, func() {
+		q.DNSSEC = true
+		m := q.message()
+		So(m.IsEdns0(), ShouldNotBeNil)
+		So(m.IsEdns0().Do(), ShouldBeTrue)
+		So(m.CheckingDisabled, ShouldBeFalse)
+
+		So(dnssecQuestion("example.test", dns.TypeA, true).CheckingDisabled, ShouldBeTrue)
+	})
+}
+
+func TestNewResult_Signatures(t *testing.T) {
+	a, _ := dns.NewRR("example.test. 300 IN A 192.0.2.1")
+	sig, _ := dns.NewRR("example.test. 300 IN RRSIG A 13 2 300 20180201000000 20180101000000 12345 example.test. c2ln")
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.test.", dns.TypeA)
+	m.Answer = []dns.RR{a, sig}
+
+	Convey("signatures are left out of the answer", t, func() {
+		So(hasSignatures(m), ShouldBeTrue)
+		So(newResult(Server{}, m, 0, nil).Answer, ShouldEqual, "192.0.2.1")
+	})
+
+	Convey("unless they were asked for", t, func() {
+		m.Question[0].Qtype = dns.TypeRRSIG
+		So(newResult(Server{}, m, 0, nil).Answer, ShouldStartWith, "192.0.2.1\nA 13 2 300")
+	})
+}
+
+func TestQueryResults_DNSSECTextSummary(t *testing.T) {
+	Convey("nothing is shown if DNSSEC was not checked", t, func() {
+		qr := QueryResults{"a": &Result{Answer: "192.0.2.1"}}
+		So(qr.dnssecTextSummary(), ShouldBeEmpty)
+	})
+
+	Convey("servers are counted by validation", t, func() {
+		qr := QueryResults{
+			"a": &Result{Answer: "192.0.2.1", AD: true, RRSIG: true, Validation: ValidationValidating},
+			"b": &Result{Answer: "192.0.2.1", RRSIG: true, Validation: ValidationNonValidating},
+			"c": &Result{Answer: "192.0.2.1", Validation: ValidationNonValidating},
+			"d": &Result{Error: "TIMEOUT", Validation: ValidationUnknown},
+		}
+
+		So(qr.dnssecTextSummary(), ShouldEqual, "\nAnd here is how they handle DNSSEC;\n\n"+
+			"1 servers validate signatures\n"+
+			"2 servers do not validate signatures\n"+
+			"1 servers could not be checked\n"+
+			"1 servers set the authenticated data (AD) flag\n"+
+			"2 servers returned signatures\n")
+	})
+}
+
+func TestValidationProbes(t *testing.T) {
+	Convey("a server that has been probed is not probed again", t, func() {
+		probes := newValidationProbes()
+		probed := &validationProbe{validation: ValidationValidating}
+		probed.once.Do(func() {})
+		probes.probes["192.0.2.1"] = probed
+
+		So(probes.check(Server{IP: "192.0.2.1"}), ShouldEqual, ValidationValidating)
+		So(probes.probes, ShouldHaveLength, 1)
+	})
+}
@@ -0,0 +1,214 @@
+package dnsyo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Default prefix lengths used when a client subnet is given as a bare address, the longest most resolvers will forward.
+const (
+	defaultECSPrefix4 = 24
+	defaultECSPrefix6 = 56
+)
+
+// ECSMatrix holds the results of a query repeated for several client subnets.
+type ECSMatrix struct {
+	Query    *Query
+	Prefixes []string
+	Results  map[string]QueryResults
+}
+
+// parseClientSubnet creates the EDNS0 client subnet option for a prefix such as 192.0.2.0/24 or 2001:db8::/56.
+// A bare address uses the default prefix length for its family.
+func parseClientSubnet(prefix string) (*dns.EDNS0_SUBNET, error) {
+	if !strings.Contains(prefix, "/") {
+		ip := net.ParseIP(prefix)
+		switch {
+		case ip == nil:
+			return nil, fmt.Errorf("invalid client subnet %s", prefix)
+		case ip.To4() != nil:
+			prefix = fmt.Sprintf("%s/%d", prefix, defaultECSPrefix4)
+		default:
+			prefix = fmt.Sprintf("%s/%d", prefix, defaultECSPrefix6)
+		}
+	}
+
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client subnet %s", prefix)
+	}
+
+	ones, _ := network.Mask.Size()
+	subnet := &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		SourceNetmask: uint8(ones),
+		Address:       network.IP,
+	}
+	if ip4 := network.IP.To4(); ip4 != nil {
+		subnet.Family = 1
+		subnet.Address = ip4
+	} else {
+		subnet.Family = 2
+	}
+	return subnet, nil
+}
+
+// SetClientSubnet checks the prefix is a valid client subnet and sets it on the query in its canonical form.
+func (q *Query) SetClientSubnet(prefix string) error {
+	subnet, err := parseClientSubnet(prefix)
+	if err != nil {
+		return err
+	}
+	q.ClientSubnet = fmt.Sprintf("%s/%d", subnet.Address, subnet.SourceNetmask)
+	return nil
+}
+
+// responseScope finds the scope prefix length of the client subnet option in a response, or nil if there is none.
+func responseScope(resp *dns.Msg) *uint8 {
+	opt := resp.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		if subnet, ok := o.(*dns.EDNS0_SUBNET); ok {
+			scope := subnet.SourceScope
+			return &scope
+		}
+	}
+	return nil
+}
+
+// ExecuteECSQuery runs the query once for each of the client subnets, so that the answers for each can be compared.
+// The prefixes are checked before any queries are made.
+func (sl *ServerList) ExecuteECSQuery(q *Query, prefixes []string, threads int) (*ECSMatrix, error) {
+	m := &ECSMatrix{
+		Query:   q,
+		Results: make(map[string]QueryResults),
+	}
+
+	var queries []*Query
+	for _, prefix := range prefixes {
+		pq := *q
+		if err := pq.SetClientSubnet(prefix); err != nil {
+			return nil, err
+		}
+		if _, ok := m.Results[pq.ClientSubnet]; !ok {
+			m.Prefixes = append(m.Prefixes, pq.ClientSubnet)
+			m.Results[pq.ClientSubnet] = nil
+			queries = append(queries, &pq)
+		}
+	}
+
+	probes := newValidationProbes()
+	for _, pq := range queries {
+		m.Results[pq.ClientSubnet] = sl.executeQuery(pq, threads, probes)
+	}
+	return m, nil
+}
+
+// answers lists the distinct answers and errors given for any of the prefixes, most common first.
+func (m *ECSMatrix) answers() (answers []string) {
+	counts := make(map[string]int)
+	for _, qr := range m.Results {
+		for _, r := range qr {
+			counts[resultText(r)]++
+		}
+	}
+
+	for a := range counts {
+		answers = append(answers, a)
+	}
+	sort.Slice(answers, func(i, j int) bool {
+		if counts[answers[i]] != counts[answers[j]] {
+			return counts[answers[i]] > counts[answers[j]]
+		}
+		return answers[i] < answers[j]
+	})
+	return
+}
+
+// resultText is the answer of a result on a single line, or its error.
+func resultText(r *Result) string {
+	if r.Error != "" {
+		return r.Error
+	}
+	return strings.Replace(normaliseAnswer(r.Answer), "\n", ", ", -1)
+}
+
+// scopes summarises the scope prefix lengths returned for one of the prefixes.
+func scopes(qr QueryResults) string {
+	counts := make(map[uint8]int)
+	var missing int
+	for _, r := range qr {
+		if r.ECSScope == nil {
+			missing++
+			continue
+		}
+		counts[*r.ECSScope]++
+	}
+
+	var lengths []int
+	for l := range counts {
+		lengths = append(lengths, int(l))
+	}
+	sort.Ints(lengths)
+
+	var parts []string
+	for _, l := range lengths {
+		parts = append(parts, fmt.Sprintf("/%d x%d", l, counts[uint8(l)]))
+	}
+	if missing > 0 {
+		parts = append(parts, fmt.Sprintf("none x%d", missing))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ToTextSummary prints a matrix of the number of servers giving each answer for each client subnet, followed by the
+// scopes the servers returned.
+func (m *ECSMatrix) ToTextSummary() (text string) {
+	text = fmt.Sprintf("\n - CLIENT SUBNETS\nI asked for %s records related to %s from %d client subnets\n\n",
+		m.Query.describeType(), m.Query.DisplayDomain(), len(m.Prefixes))
+
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "ANSWER\t%s\n", strings.Join(m.Prefixes, "\t"))
+	for _, a := range m.answers() {
+		fmt.Fprint(w, a)
+		for _, p := range m.Prefixes {
+			var count int
+			for _, r := range m.Results[p] {
+				if resultText(r) == a {
+					count++
+				}
+			}
+			fmt.Fprintf(w, "\t%d", count)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	text += b.String()
+
+	text += "\nAnd here are the scopes returned;\n\n"
+	for _, p := range m.Prefixes {
+		text += fmt.Sprintf("%s\t%s\n", p, scopes(m.Results[p]))
+	}
+
+	return text
+}
+
+// ToJSON prints the results for each client subnet as JSON.
+func (m *ECSMatrix) ToJSON() (string, error) {
+	text, err := json.Marshal(struct {
+		Domain  string
+		IDN     string `json:",omitempty"`
+		Type    string
+		Results map[string]QueryResults
+	}{m.Query.Domain, m.Query.IDN, m.Query.GetType(), m.Results})
+	return string(text), err
+}
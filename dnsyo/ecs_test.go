@@ -0,0 +1,95 @@
+package dnsyo
+
+import (
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"net"
+	"testing"
+)
+
+func TestParseClientSubnet(t *testing.T) {
+	Convey("prefixes are parsed for both families", t, func() {
+		subnet, err := parseClientSubnet("192.0.2.77/24")
+		So(err, ShouldBeNil)
+		So(subnet.Family, ShouldEqual, 1)
+		So(subnet.SourceNetmask, ShouldEqual, 24)
+		So(subnet.Address.String(), ShouldEqual, "192.0.2.0")
+
+		subnet, err = parseClientSubnet("2001:db8:1234::/48")
+		So(err, ShouldBeNil)
+		So(subnet.Family, ShouldEqual, 2)
+		So(subnet.SourceNetmask, ShouldEqual, 48)
+	})
+
+	Convey("bare addresses use the default prefix length", t, func() {
+		q := &Query{}
+		So(q.SetClientSubnet("198.51.100.20"), ShouldBeNil)
+		So(q.ClientSubnet, ShouldEqual, "198.51.100.0/24")
+
+		So(q.SetClientSubnet("2001:db8::1"), ShouldBeNil)
+		So(q.ClientSubnet, ShouldEqual, "2001:db8::/56")
+	})
+
+	Convey("invalid prefixes are rejected", t, func() {
+		q := &Query{}
+		So(q.SetClientSubnet("example.com"), ShouldBeError)
+		So(q.SetClientSubnet("192.0.2.0/33"), ShouldBeError)
+	})
+}
+
+func TestQuery_ClientSubnetMessage(t *testing.T) {
+	Convey("the subnet is sent as an EDNS0 option", t, func() {
+		q := &Query{Domain: "example.test", Type: dns.TypeA, ClientSubnet: "192.0.2.0/24"}
+		opt := q.message().IsEdns0()
+		So(opt, ShouldNotBeNil)
+		So(opt.Option, ShouldHaveLength, 1)
+		So(opt.Option[0].(*dns.EDNS0_SUBNET).SourceNetmask, ShouldEqual, 24)
+	})
+
+	Convey("the scope is read from the response", t, func() {
+		resp := new(dns.Msg)
+		So(responseScope(resp), ShouldBeNil)
+
+		resp.SetEdns0(4096, false)
+		So(responseScope(resp), ShouldBeNil)
+
+		opt := resp.IsEdns0()
+		opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{
+			Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, SourceScope: 20, Address: net.ParseIP("192.0.2.0"),
+		})
+		So(*responseScope(resp), ShouldEqual, 20)
+	})
+}
+
+func TestECSMatrix(t *testing.T) {
+	var scope16, scope24 uint8 = 16, 24
+	m := &ECSMatrix{
+		Query:    &Query{Domain: "cdn.example.test", Type: dns.TypeA},
+		Prefixes: []string{"192.0.2.0/24", "198.51.100.0/24"},
+		Results: map[string]QueryResults{
+			"192.0.2.0/24": {
+				"a": &Result{Answer: "203.0.113.1", ECSScope: &scope24},
+				"b": &Result{Answer: "203.0.113.1"},
+			},
+			"198.51.100.0/24": {
+				"a": &Result{Answer: "203.0.113.2\n203.0.113.3", ECSScope: &scope16},
+				"b": &Result{Error: "TIMEOUT"},
+			},
+		},
+	}
+
+	Convey("answers are counted for each prefix", t, func() {
+		text := m.ToTextSummary()
+		So(text, ShouldContainSubstring, "from 2 client subnets")
+		So(text, ShouldContainSubstring, "ANSWER                    192.0.2.0/24  198.51.100.0/24\n")
+		So(text, ShouldContainSubstring, "203.0.113.1               2             0\n")
+		So(text, ShouldContainSubstring, "203.0.113.2, 203.0.113.3  0             1\n")
+		So(text, ShouldContainSubstring, "192.0.2.0/24\t/24 x1, none x1\n198.51.100.0/24\t/16 x1, none x1\n")
+	})
+
+	Convey("results are grouped by prefix in JSON", t, func() {
+		text, err := m.ToJSON()
+		So(err, ShouldBeNil)
+		So(text, ShouldContainSubstring, `"198.51.100.0/24":{"a":{"Answer":"203.0.113.2\n203.0.113.3","ECSScope":16}`)
+	})
+}
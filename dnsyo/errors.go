@@ -0,0 +1,137 @@
+package dnsyo
+
+import (
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"os"
+	"syscall"
+)
+
+// Codes identifying the kind of LookupError, which are stable for use in JSON and the API.
+const (
+	CodeTimeout     = "timeout"
+	CodeRefused     = "refused"
+	CodeUnreachable = "unreachable"
+	CodeNetwork     = "network"
+	CodeTruncated   = "truncated"
+	CodeMalformed   = "malformed"
+	CodeIDMismatch  = "id_mismatch"
+	CodeNoAnswer    = "no_answer"
+	CodeRcode       = "rcode"
+)
+
+// LookupError describes why a server failed to answer a query. The error message is the label used to group errors
+// in summaries, while Code is stable for machine use.
+//
+// The exported errors can be compared with errors.Is, which matches any error of the same kind, and errors.As can be
+// used to get the Code, Rcode and underlying error.
+type LookupError struct {
+	Code  string
+	Label string
+
+	// Rcode is the response code of CodeRcode errors
+	Rcode int
+
+	// Err is the error returned by the dns client, if there was one
+	Err error
+}
+
+// Errors returned when a server fails to answer a query
+var (
+	ErrTimeout     = &LookupError{Code: CodeTimeout, Label: "TIMEOUT"}
+	ErrRefused     = &LookupError{Code: CodeRefused, Label: "CONNECTION REFUSED"}
+	ErrUnreachable = &LookupError{Code: CodeUnreachable, Label: "UNREACHABLE"}
+	ErrNetwork     = &LookupError{Code: CodeNetwork, Label: "NETWORK ERROR"}
+	ErrTruncated   = &LookupError{Code: CodeTruncated, Label: "TRUNCATED"}
+	ErrMalformed   = &LookupError{Code: CodeMalformed, Label: "MALFORMED RESPONSE"}
+	ErrIDMismatch  = &LookupError{Code: CodeIDMismatch, Label: "ID MISMATCH"}
+	ErrNoAnswer    = &LookupError{Code: CodeNoAnswer, Label: "NOANSWER"}
+
+	// ErrRcode matches any error response with errors.Is, use RcodeError to match a particular response code
+	ErrRcode = &LookupError{Code: CodeRcode, Label: "RCODE"}
+)
+
+// RcodeError creates the error for a response with a failure response code, labelled with its name such as NXDOMAIN.
+func RcodeError(rcode int) *LookupError {
+	label, ok := dns.RcodeToString[rcode]
+	if !ok {
+		label = fmt.Sprintf("RCODE%d", rcode)
+	}
+	return &LookupError{Code: CodeRcode, Label: label, Rcode: rcode}
+}
+
+// Error returns the label of the error.
+func (e *LookupError) Error() string {
+	return e.Label
+}
+
+// Unwrap returns the error from the dns client that caused the lookup to fail, if there was one.
+func (e *LookupError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors of the same kind, so that the exported errors can be used with errors.Is. Errors for response codes
+// only match the same response code, or ErrRcode.
+func (e *LookupError) Is(target error) bool {
+	t, ok := target.(*LookupError)
+	if !ok || t.Code != e.Code {
+		return false
+	}
+	return t.Code != CodeRcode || t == ErrRcode || t.Rcode == e.Rcode
+}
+
+// wrap creates an error of the same kind caused by err.
+func (e *LookupError) wrap(err error) *LookupError {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+// classifyError converts the errors returned by the dns client to a LookupError so that they can be grouped.
+// Errors that are not recognised are network errors.
+func classifyError(err error) *LookupError {
+	if le, ok := err.(*LookupError); ok {
+		return le
+	}
+
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return ErrTimeout.wrap(err)
+	}
+
+	if err == dns.ErrId {
+		return ErrIDMismatch.wrap(err)
+	}
+	if _, ok := err.(*dns.Error); ok {
+		return ErrMalformed.wrap(err)
+	}
+
+	if errno, ok := syscallErrno(err); ok {
+		switch errno {
+		case syscall.ECONNREFUSED:
+			return ErrRefused.wrap(err)
+		case syscall.EHOSTUNREACH, syscall.ENETUNREACH:
+			return ErrUnreachable.wrap(err)
+		}
+	}
+
+	// an ICMP port unreachable for a UDP query shows up when reading the response
+	if oe, ok := err.(*net.OpError); ok && oe.Op == "read" {
+		return ErrRefused.wrap(err)
+	}
+
+	return ErrNetwork.wrap(err)
+}
+
+// syscallErrno finds the system call error number underneath a network error.
+func syscallErrno(err error) (syscall.Errno, bool) {
+	switch t := err.(type) {
+	case syscall.Errno:
+		return t, true
+	case *os.SyscallError:
+		return syscallErrno(t.Err)
+	case *net.OpError:
+		return syscallErrno(t.Err)
+	}
+	return 0, false
+}
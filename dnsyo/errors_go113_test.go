@@ -0,0 +1,48 @@
+//go:build go1.13
+// +build go1.13
+
+package dnsyo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+// These tests use errors.Is and errors.As, which were added in Go 1.13, so they are only built by newer versions.
+
+func TestLookupError_errorsIs(t *testing.T) {
+	Convey("wrapped lookup errors match their sentinel", t, func() {
+		err := fmt.Errorf("querying example.com: %w", ErrTimeout.wrap(timeoutError{}))
+		So(errors.Is(err, ErrTimeout), ShouldBeTrue)
+		So(errors.Is(err, ErrRefused), ShouldBeFalse)
+		So(errors.Is(err, timeoutError{}), ShouldBeTrue)
+	})
+
+	Convey("wrapped rcode errors match the same response code or any rcode error", t, func() {
+		err := fmt.Errorf("querying example.com: %w", RcodeError(dns.RcodeNameError))
+		So(errors.Is(err, RcodeError(dns.RcodeNameError)), ShouldBeTrue)
+		So(errors.Is(err, RcodeError(dns.RcodeServerFailure)), ShouldBeFalse)
+		So(errors.Is(err, ErrRcode), ShouldBeTrue)
+		So(errors.Is(err, ErrNoAnswer), ShouldBeFalse)
+	})
+}
+
+func TestLookupError_errorsAs(t *testing.T) {
+	Convey("the lookup error can be found in a wrapped error", t, func() {
+		err := fmt.Errorf("querying example.com: %w", RcodeError(dns.RcodeNameError))
+
+		var le *LookupError
+		So(errors.As(err, &le), ShouldBeTrue)
+		So(le.Code, ShouldEqual, CodeRcode)
+		So(le.Rcode, ShouldEqual, dns.RcodeNameError)
+		So(le.Error(), ShouldEqual, "NXDOMAIN")
+	})
+
+	Convey("other errors are not lookup errors", t, func() {
+		var le *LookupError
+		So(errors.As(fmt.Errorf("wrapped: %w", timeoutError{}), &le), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,66 @@
+package dnsyo
+
+import (
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestLookupError_Is(t *testing.T) {
+	Convey("errors match others of the same kind", t, func() {
+		err := ErrTimeout.wrap(timeoutError{})
+		So(err.Is(ErrTimeout), ShouldBeTrue)
+		So(err.Is(ErrRefused), ShouldBeFalse)
+		So(err.Unwrap(), ShouldResemble, timeoutError{})
+		So(err.Error(), ShouldEqual, "TIMEOUT")
+	})
+
+	Convey("rcode errors match the same response code or any rcode error", t, func() {
+		err := RcodeError(dns.RcodeServerFailure)
+		So(err.Error(), ShouldEqual, "SERVFAIL")
+		So(err.Is(RcodeError(dns.RcodeServerFailure)), ShouldBeTrue)
+		So(err.Is(RcodeError(dns.RcodeNameError)), ShouldBeFalse)
+		So(err.Is(ErrRcode), ShouldBeTrue)
+		So(err.Is(ErrNoAnswer), ShouldBeFalse)
+	})
+
+	Convey("unknown response codes are labelled with their number", t, func() {
+		So(RcodeError(4000).Error(), ShouldEqual, "RCODE4000")
+	})
+}
+
+func TestClassifyError(t *testing.T) {
+	opError := func(op string, err error) error {
+		return &net.OpError{Op: op, Net: "udp", Err: err}
+	}
+
+	Convey("errors from the dns client are classified", t, func() {
+		cases := map[string]error{
+			CodeTimeout:     opError("read", timeoutError{}),
+			CodeRefused:     opError("read", os.NewSyscallError("recvfrom", syscall.ECONNREFUSED)),
+			CodeUnreachable: opError("write", os.NewSyscallError("sendto", syscall.ENETUNREACH)),
+			CodeIDMismatch:  dns.ErrId,
+			CodeMalformed:   dns.ErrRdata,
+			CodeNetwork:     opError("dial", os.NewSyscallError("socket", syscall.EMFILE)),
+		}
+
+		for code, err := range cases {
+			le := classifyError(err)
+			So(le.Code, ShouldEqual, code)
+			So(le.Unwrap(), ShouldEqual, err)
+		}
+	})
+
+	Convey("lookup errors are not classified again", t, func() {
+		So(classifyError(ErrNoAnswer), ShouldEqual, ErrNoAnswer)
+	})
+}
@@ -0,0 +1,296 @@
+package dnsyo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultFetchTimeout = 60 * time.Second // maximum time to download the csv, including reading the body
+	defaultFetchRetries = 2                // number of additional attempts made after a network or server error
+	defaultRetryDelay   = time.Second      // delay before the first retry, doubled for each subsequent retry
+)
+
+// CSVFetcher downloads the public-dns.info CSV, optionally keeping a copy in CacheFile.
+// When a cached copy is available, conditional requests are made using the ETag and Last-Modified headers of the
+// previous download so the file is only downloaded again when it has changed.
+type CSVFetcher struct {
+	URL       string
+	CacheFile string
+
+	// Offline uses the cached copy without making any requests
+	Offline bool
+
+	Timeout    time.Duration
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// csvCacheMeta is stored alongside the cached CSV to allow conditional requests.
+type csvCacheMeta struct {
+	URL          string
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+	FetchedAt    time.Time
+}
+
+// NewCSVFetcher creates a CSVFetcher for the given URL with the default timeout and retry policy.
+// If cacheFile is empty the download is not cached.
+func NewCSVFetcher(url, cacheFile string) *CSVFetcher {
+	return &CSVFetcher{
+		URL:        url,
+		CacheFile:  cacheFile,
+		Timeout:    defaultFetchTimeout,
+		Retries:    defaultFetchRetries,
+		RetryDelay: defaultRetryDelay,
+	}
+}
+
+// Servers fetches the CSV and loads the servers from it as ServersFromCSV.
+func (f *CSVFetcher) Servers() (sl ServerList, err error) {
+	r, err := f.Open()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	return ServersFromCSV(r)
+}
+
+// Open returns a reader for the current CSV, either streamed from the URL, or from the cache if the file has not
+// changed or the fetcher is offline. The caller must close the reader.
+func (f *CSVFetcher) Open() (io.ReadCloser, error) {
+	if f.Offline {
+		if f.CacheFile == "" {
+			return nil, errors.New("no csv cache file configured for offline use")
+		}
+
+		if meta := f.readMeta(); meta != nil {
+			log.WithFields(log.Fields{
+				"url":     meta.URL,
+				"fetched": meta.FetchedAt,
+			}).Info("Using cached nameserver list")
+		}
+		r, err := os.Open(f.CacheFile)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no cached copy of %s available for offline use", f.URL)
+		}
+		return r, err
+	}
+
+	meta := f.readMeta()
+	resp, err := f.get(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if f.CacheFile == "" {
+			return resp.Body, nil
+		}
+
+		err = writeStreamAtomic(f.CacheFile, resp.Body, 0644)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		f.writeMeta(&csvCacheMeta{
+			URL:          f.URL,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			FetchedAt:    time.Now(),
+		})
+
+	case http.StatusNotModified:
+		resp.Body.Close()
+		if meta == nil {
+			return nil, fmt.Errorf("unexpected status %s fetching %s without a cached copy", resp.Status, f.URL)
+		}
+		log.WithField("url", f.URL).Debug("Nameserver list not modified, using cached copy")
+
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s fetching %s", resp.Status, f.URL)
+	}
+
+	return os.Open(f.CacheFile)
+}
+
+// get requests the CSV, retrying on network errors and server errors with an increasing delay.
+// If meta is not nil the request is made conditional on the cached copy.
+func (f *CSVFetcher) get(meta *csvCacheMeta) (resp *http.Response, err error) {
+	client := &http.Client{Timeout: f.Timeout}
+
+	for attempt := 0; ; attempt++ {
+		var req *http.Request
+		req, err = http.NewRequest(http.MethodGet, f.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if meta != nil {
+			if meta.ETag != "" {
+				req.Header.Set("If-None-Match", meta.ETag)
+			}
+			if meta.LastModified != "" {
+				req.Header.Set("If-Modified-Since", meta.LastModified)
+			}
+		}
+
+		resp, err = client.Do(req)
+		retry := err != nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		if !retry || attempt >= f.Retries {
+			return resp, err
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			resp.Body.Close()
+		}
+		delay := f.RetryDelay << uint(attempt)
+		log.WithFields(log.Fields{
+			"url":    f.URL,
+			"reason": reason,
+			"delay":  delay,
+		}).Warn("Failed to fetch nameserver list, retrying")
+		time.Sleep(delay)
+	}
+}
+
+// readMeta loads the metadata for the cached copy, returning nil if there is no usable cached copy of the URL.
+func (f *CSVFetcher) readMeta() *csvCacheMeta {
+	if f.CacheFile == "" {
+		return nil
+	}
+	if _, err := os.Stat(f.CacheFile); err != nil {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(f.CacheFile + ".meta")
+	if err != nil {
+		return nil
+	}
+
+	meta := new(csvCacheMeta)
+	if err := json.Unmarshal(data, meta); err != nil || meta.URL != f.URL {
+		return nil
+	}
+	return meta
+}
+
+// writeMeta saves the metadata for the cached copy. Failure only means the next request will not be conditional.
+func (f *CSVFetcher) writeMeta(meta *csvCacheMeta) {
+	data, err := json.Marshal(meta)
+	if err == nil {
+		err = writeFileAtomic(f.CacheFile+".meta", data, 0644)
+	}
+	if err != nil {
+		log.WithField("file", f.CacheFile).Warn("Unable to save nameserver list cache details: " + err.Error())
+	}
+}
+
+// readCSVNameservers reads the public-dns.info CSV row by row, calling fn for each nameserver.
+// Columns are matched by the names in the header, as in the csv tags on csvNameserver, so their order does not matter.
+//
+// An incomplete or malformed final row is assumed to be the result of a truncated download and is ignored, whereas
+// the same problem on any other row is an error.
+func readCSVNameservers(r io.Reader, fn func(ns csvNameserver)) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("unable to read csv header: %s", err)
+	}
+	cols := make(map[string]int)
+	for i, name := range header {
+		cols[name] = i
+	}
+	if _, ok := cols["ip"]; !ok {
+		return errors.New("csv does not contain an ip column")
+	}
+
+	row, err := cr.Read()
+	for n := 2; err != io.EOF; n++ {
+		next, nextErr := cr.Read()
+		last := nextErr == io.EOF
+
+		var ns csvNameserver
+		if err == nil {
+			if len(row) < len(header) {
+				err = fmt.Errorf("row %d has %d of %d columns", n, len(row), len(header))
+			} else {
+				ns, err = decodeCSVNameserver(cols, row)
+				if err != nil {
+					err = fmt.Errorf("row %d: %s", n, err)
+				}
+			}
+		}
+
+		if err != nil {
+			if last {
+				log.Debug("Ignoring truncated final row of csv: " + err.Error())
+				return nil
+			}
+			return err
+		}
+
+		fn(ns)
+		row, err = next, nextErr
+	}
+
+	return nil
+}
+
+// decodeCSVNameserver converts a single row of the CSV using the column indexes from the header
+func decodeCSVNameserver(cols map[string]int, row []string) (ns csvNameserver, err error) {
+	get := func(name string) string {
+		if i, ok := cols[name]; ok {
+			return row[i]
+		}
+		return ""
+	}
+
+	ns.IPAddress = get("ip")
+	ns.Name = get("name")
+	ns.Country = get("country_id")
+	ns.City = get("city")
+	ns.Version = get("version")
+	ns.Error = get("error")
+
+	if v := get("dnssec"); v != "" {
+		if ns.DNSSec, err = strconv.ParseBool(v); err != nil {
+			return
+		}
+	}
+	if v := get("reliability"); v != "" {
+		if ns.Reliability, err = strconv.ParseFloat(v, 64); err != nil {
+			return
+		}
+	}
+	if v := get("checked_at"); v != "" {
+		if ns.CheckedAt, err = time.Parse(time.RFC3339, v); err != nil {
+			return
+		}
+	}
+	if v := get("created_at"); v != "" {
+		if ns.CreatedAt, err = time.Parse(time.RFC3339, v); err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,207 @@
+package dnsyo
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	tmpCSVCache = ".csv-cache-test.csv"
+	testCSV     = `ip,name,country_id,city,version,error,dnssec,reliability,checked_at,created_at
+84.200.69.80,resolver1.ihgip.net.,DE,,,,true,1.00,2018-01-27T03:49:10Z,2015-01-01T00:00:00Z
+148.251.43.199,static.199.43.251.148.clients.your-server.de.,DE,,,,false,0.50,2018-01-27T03:49:10Z,2015-01-01T00:00:00Z
+2001:1608:10:25::1c04:b12f,,DE,,,,true,1.00,2018-01-27T03:49:10Z,2015-01-01T00:00:00Z
+8.8.8.8,google-public-dns-a.google.com.,us,,,,true,0.99,2018-01-27T03:49:10Z,2015-01-01T00:00:00Z
+`
+)
+
+func TestServersFromCSV(t *testing.T) {
+	dnswatch1 := Server{IP: "84.200.69.80", Name: "resolver1.ihgip.net.", Country: "DE", DNSSEC: true}
+	googleA := Server{IP: "8.8.8.8", Name: "google-public-dns-a.google.com.", Country: "US", DNSSEC: true}
+
+	Convey("unreliable and IPv6 servers are filtered out", t, func() {
+		sl, err := ServersFromCSV(strings.NewReader(testCSV))
+		So(err, ShouldBeNil)
+		So(sl, ShouldResemble, ServerList{dnswatch1, googleA})
+	})
+
+	Convey("a truncated final row is ignored", t, func() {
+		Convey("missing columns", func() {
+			sl, err := ServersFromCSV(strings.NewReader(testCSV + "1.1.1.1,one.one.one.one,AU,,"))
+			So(err, ShouldBeNil)
+			So(sl, ShouldHaveLength, 2)
+		})
+
+		Convey("part of a value", func() {
+			sl, err := ServersFromCSV(strings.NewReader(testCSV + "1.1.1.1,one.one.one.one,AU,,,,true,1.00,2018-01-27T03:49:10Z,2015-01"))
+			So(err, ShouldBeNil)
+			So(sl, ShouldHaveLength, 2)
+		})
+
+		Convey("an unterminated quote", func() {
+			sl, err := ServersFromCSV(strings.NewReader(testCSV + `1.1.1.1,"one.one`))
+			So(err, ShouldBeNil)
+			So(sl, ShouldHaveLength, 2)
+		})
+	})
+
+	Convey("a broken row before the end is an error", t, func() {
+		csv := strings.Replace(testCSV, "true,1.00", "true,high", 1)
+		_, err := ServersFromCSV(strings.NewReader(csv))
+		So(err, ShouldBeError)
+		So(err.Error(), ShouldContainSubstring, "row 2")
+	})
+
+	Convey("the ip column is required", t, func() {
+		_, err := ServersFromCSV(strings.NewReader("name,country_id\nfoo,GB\n"))
+		So(err, ShouldBeError)
+	})
+}
+
+func TestCSVFetcher(t *testing.T) {
+	var mtx sync.Mutex
+	var requests, failures int
+	var lastETag string
+	count := func() int {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return requests
+	}
+	etag := func() string {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return lastETag
+	}
+	fail := func(n int) {
+		mtx.Lock()
+		failures = n
+		mtx.Unlock()
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		requests++
+		lastETag = r.Header.Get("If-None-Match")
+
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/missing.csv":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if lastETag == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testCSV))
+	}))
+	defer server.Close()
+
+	newFetcher := func(path string) *CSVFetcher {
+		f := NewCSVFetcher(server.URL+path, tmpCSVCache)
+		f.RetryDelay = time.Millisecond
+		return f
+	}
+
+	cleanup := func() {
+		os.Remove(tmpCSVCache)
+		os.Remove(tmpCSVCache + ".meta")
+	}
+	defer cleanup()
+
+	Convey("with an empty cache", t, func() {
+		cleanup()
+		mtx.Lock()
+		requests = 0
+		mtx.Unlock()
+
+		Convey("the list is downloaded and cached", func() {
+			sl, err := newFetcher("/nameservers.csv").Servers()
+			So(err, ShouldBeNil)
+			So(sl, ShouldHaveLength, 2)
+			So(count(), ShouldEqual, 1)
+
+			_, err = os.Stat(tmpCSVCache)
+			So(err, ShouldBeNil)
+
+			Convey("the next request is conditional and uses the cache", func() {
+				sl, err := newFetcher("/nameservers.csv").Servers()
+				So(err, ShouldBeNil)
+				So(sl, ShouldHaveLength, 2)
+				So(count(), ShouldEqual, 2)
+				So(etag(), ShouldEqual, `"v1"`)
+			})
+
+			Convey("offline mode uses the cache without a request", func() {
+				f := newFetcher("/nameservers.csv")
+				f.Offline = true
+
+				sl, err := f.Servers()
+				So(err, ShouldBeNil)
+				So(sl, ShouldHaveLength, 2)
+				So(count(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("offline mode fails without a cached copy", func() {
+			f := newFetcher("/nameservers.csv")
+			f.Offline = true
+
+			_, err := f.Servers()
+			So(err, ShouldBeError)
+			So(count(), ShouldEqual, 0)
+		})
+
+		Convey("server errors are retried", func() {
+			fail(2)
+			sl, err := newFetcher("/nameservers.csv").Servers()
+			So(err, ShouldBeNil)
+			So(sl, ShouldHaveLength, 2)
+			So(count(), ShouldEqual, 3)
+		})
+
+		Convey("retries are limited", func() {
+			fail(10)
+			_, err := newFetcher("/nameservers.csv").Servers()
+			So(err, ShouldBeError)
+			So(err.Error(), ShouldContainSubstring, "503")
+			So(count(), ShouldEqual, defaultFetchRetries+1)
+			fail(0)
+		})
+
+		Convey("other statuses are errors", func() {
+			_, err := newFetcher("/missing.csv").Servers()
+			So(err, ShouldBeError)
+			So(err.Error(), ShouldContainSubstring, "404")
+			So(count(), ShouldEqual, 1)
+		})
+	})
+
+	Convey("without a cache the list is streamed", t, func() {
+		cleanup()
+
+		f := newFetcher("/nameservers.csv")
+		f.CacheFile = ""
+		sl, err := f.Servers()
+		So(err, ShouldBeNil)
+		So(sl, ShouldHaveLength, 2)
+
+		_, err = os.Stat(tmpCSVCache)
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,104 @@
+package dnsyo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// softwareNames maps the names that appear in version strings to the software they identify, checked in order.
+var softwareNames = []struct {
+	match, name string
+}{
+	{"unbound", "Unbound"},
+	{"powerdns", "PowerDNS Recursor"},
+	{"pdns", "PowerDNS Recursor"},
+	{"dnsmasq", "dnsmasq"},
+	{"knot", "Knot Resolver"},
+	{"kresd", "Knot Resolver"},
+	{"microsoft", "Microsoft DNS"},
+	{"bind", "BIND"},
+}
+
+// versionNumber finds a version number such as 9.11.3 in a version string.
+var versionNumber = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// bindVersion matches the bare version numbers that BIND reports, such as 9.11.3-P1 or 9.9.4-RedHat-9.9.4-61.el7.
+var bindVersion = regexp.MustCompile(`^9\.\d+`)
+
+// identifySoftware recognises the software from the version string a server reports, returning its name followed by
+// the version number if there is one. Returns an empty string if the software is not recognised, as the version is
+// often replaced with an arbitrary string to hide it.
+func identifySoftware(version string) string {
+	lower := strings.ToLower(strings.TrimSpace(version))
+	if lower == "" {
+		return ""
+	}
+
+	name := ""
+	for _, s := range softwareNames {
+		if strings.Contains(lower, s.match) {
+			name = s.name
+			break
+		}
+	}
+	if name == "" && bindVersion.MatchString(lower) {
+		name = "BIND"
+	}
+	if name == "" {
+		return ""
+	}
+
+	if v := versionNumber.FindString(lower); v != "" {
+		return name + " " + v
+	}
+	return name
+}
+
+// Fingerprint identifies the software the server is running from the version it reports to the version.bind and
+// version.server CHAOS TXT queries. If the version is hidden, servers that answer authors.bind are identified as BIND.
+// Returns an empty string if the software could not be identified.
+func (s *Server) Fingerprint() string {
+	if software := identifySoftware(s.chaosTXT("version.bind.", "version.server.")); software != "" {
+		return software
+	}
+
+	if s.chaosTXT("authors.bind.") != "" {
+		return "BIND"
+	}
+
+	return ""
+}
+
+// FingerprintAll identifies the software of each server in the list, clearing the Software of those that could not be
+// identified so that it is never left over from an earlier run. The number of servers identified is returned.
+func (sl *ServerList) FingerprintAll(threads int) (identified int) {
+	var mtx sync.Mutex
+
+	runParallel(len(*sl), threads, func(i int) {
+		software := (*sl)[i].Fingerprint()
+
+		mtx.Lock()
+		(*sl)[i].Software = software
+		if software != "" {
+			identified++
+		}
+		mtx.Unlock()
+	})
+	return
+}
+
+// FilterSoftware returns the servers running software whose name contains the given name, ignoring case.
+// Returns an error if no servers were found.
+func (sl *ServerList) FilterSoftware(software string) (fl ServerList, err error) {
+	fl = sl.Filter(func(s Server) bool {
+		return strings.Contains(strings.ToLower(s.Software), strings.ToLower(software))
+	})
+
+	if len(fl) == 0 {
+		err = fmt.Errorf("no servers running %s were found", software)
+	}
+
+	return
+}
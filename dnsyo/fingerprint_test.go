@@ -0,0 +1,49 @@
+package dnsyo
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestIdentifySoftware(t *testing.T) {
+	Convey("version strings are recognised", t, func() {
+		cases := map[string]string{
+			"unbound 1.6.0":                     "Unbound 1.6.0",
+			"PowerDNS Recursor 4.1.1 (built)":   "PowerDNS Recursor 4.1.1",
+			"dnsmasq-2.78":                      "dnsmasq 2.78",
+			"Knot Resolver 2.1.1":               "Knot Resolver 2.1.1",
+			"9.11.3-1ubuntu1.1-Ubuntu":          "BIND 9.11.3",
+			"9.9.4-RedHat-9.9.4-61.el7":         "BIND 9.9.4",
+			"BIND":                              "BIND",
+			"Microsoft DNS 6.1.7601 (1DB1446A)": "Microsoft DNS 6.1.7601",
+		}
+		for version, software := range cases {
+			So(identifySoftware(version), ShouldEqual, software)
+		}
+	})
+
+	Convey("hidden versions are not recognised", t, func() {
+		So(identifySoftware(""), ShouldBeEmpty)
+		So(identifySoftware("none of your business"), ShouldBeEmpty)
+		So(identifySoftware("1.2.3"), ShouldBeEmpty)
+	})
+}
+
+func TestServerList_FilterSoftware(t *testing.T) {
+	sl := ServerList{
+		{IP: "127.0.0.1", Software: "Unbound 1.6.0"},
+		{IP: "127.0.0.2", Software: "BIND 9.11.3"},
+		{IP: "127.0.0.3"},
+	}
+
+	Convey("servers are matched by name ignoring case", t, func() {
+		fl, err := sl.FilterSoftware("unbound")
+		So(err, ShouldBeNil)
+		So(fl, ShouldResemble, ServerList{sl[0]})
+	})
+
+	Convey("no matching servers is an error", t, func() {
+		_, err := sl.FilterSoftware("dnsmasq")
+		So(err, ShouldBeError)
+	})
+}
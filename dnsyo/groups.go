@@ -0,0 +1,100 @@
+package dnsyo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BuiltinGroups are the well known public anycast resolvers, which can be queried by group name whether or not they
+// are in the resolver file. The "public" group contains all of them.
+var BuiltinGroups = map[string]ServerList{
+	"google": {
+		{IP: "8.8.8.8", Country: "US", Name: "dns.google"},
+		{IP: "8.8.4.4", Country: "US", Name: "dns.google"},
+	},
+	"cloudflare": {
+		{IP: "1.1.1.1", Country: "US", Name: "one.one.one.one"},
+		{IP: "1.0.0.1", Country: "US", Name: "one.one.one.one"},
+	},
+	"quad9": {
+		{IP: "9.9.9.9", Country: "CH", Name: "dns9.quad9.net"},
+		{IP: "149.112.112.112", Country: "CH", Name: "dns.quad9.net"},
+	},
+	"opendns": {
+		{IP: "208.67.222.222", Country: "US", Name: "resolver1.opendns.com"},
+		{IP: "208.67.220.220", Country: "US", Name: "resolver2.opendns.com"},
+	},
+}
+
+// publicGroup is the name of the group containing all of the BuiltinGroups
+const publicGroup = "public"
+
+// GroupNames lists the names of the built in groups in alphabetical order.
+func GroupNames() (names []string) {
+	for name := range BuiltinGroups {
+		names = append(names, name)
+	}
+	names = append(names, publicGroup)
+	sort.Strings(names)
+	return
+}
+
+// FilterGroups returns the servers in any of the named groups.
+// A group is either one of the BuiltinGroups, or the servers in the current list tagged with the group name.
+// Built in servers are included even if they are not in the current list, but the current list's entry is used if
+// it is. Returns an error if a group does not contain any servers.
+func (sl *ServerList) FilterGroups(groups []string) (fl ServerList, err error) {
+	for _, group := range groups {
+		var members ServerList
+		switch name := strings.ToLower(group); {
+		case name == publicGroup:
+			for _, g := range GroupNames() {
+				members = append(members, BuiltinGroups[g]...)
+			}
+
+		case BuiltinGroups[name] != nil:
+			members = BuiltinGroups[name]
+
+		default:
+			members = sl.Filter(func(s Server) bool {
+				return s.HasTag(group)
+			})
+		}
+
+		if len(members) == 0 {
+			return nil, fmt.Errorf("no servers in group %s were found", group)
+		}
+
+		for _, s := range members {
+			if fl.Find(s.IP) >= 0 {
+				continue
+			}
+			if i := sl.Find(s.IP); i >= 0 {
+				s = (*sl)[i]
+			}
+			fl = append(fl, s)
+		}
+	}
+
+	return
+}
+
+// FilterTags returns the servers in the current list that have any of the given tags.
+// Returns an error if no servers were found.
+func (sl *ServerList) FilterTags(tags []string) (fl ServerList, err error) {
+	fl = sl.Filter(func(s Server) bool {
+		for _, t := range tags {
+			if s.HasTag(t) {
+				return true
+			}
+		}
+		return false
+	})
+
+	if len(fl) == 0 {
+		err = fmt.Errorf("no servers tagged %s were found", strings.Join(tags, " or "))
+	}
+
+	return
+}
@@ -0,0 +1,85 @@
+package dnsyo
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestServerList_FilterGroups(t *testing.T) {
+	sl, _ := ServersFromFile(testYaml)
+	if len(sl) != 9 {
+		t.Error("incorred number of servers, double check test list")
+	}
+	sl[2].Tags = []string{"partners"}
+	sl[8].Tags = []string{"Partners"}
+
+	Convey("built in groups include servers that are not in the list", t, func() {
+		fl, err := sl.FilterGroups([]string{"cloudflare"})
+		So(err, ShouldBeNil)
+		So(fl, ShouldResemble, BuiltinGroups["cloudflare"])
+	})
+
+	Convey("the list entry is used for built in servers when there is one", t, func() {
+		fl, err := sl.FilterGroups([]string{"Google"})
+		So(err, ShouldBeNil)
+		So(fl, ShouldHaveLength, 2)
+		So(fl[0], ShouldResemble, sl[0])
+	})
+
+	Convey("public contains all of the built in groups", t, func() {
+		fl, err := sl.FilterGroups([]string{"public"})
+		So(err, ShouldBeNil)
+		So(fl, ShouldHaveLength, 8)
+	})
+
+	Convey("other groups are found by tag", t, func() {
+		fl, err := sl.FilterGroups([]string{"partners"})
+		So(err, ShouldBeNil)
+		So(fl, ShouldResemble, ServerList{sl[2], sl[8]})
+	})
+
+	Convey("groups are combined without duplicates", t, func() {
+		fl, err := sl.FilterGroups([]string{"opendns", "partners"})
+		So(err, ShouldBeNil)
+		So(fl, ShouldHaveLength, 3)
+	})
+
+	Convey("an empty group is an error", t, func() {
+		_, err := sl.FilterGroups([]string{"nobody"})
+		So(err, ShouldBeError)
+	})
+}
+
+func TestBuiltinGroups(t *testing.T) {
+	Convey("every built in server has its own result key", t, func() {
+		var sl ServerList
+		fl, err := sl.FilterGroups([]string{"public"})
+		So(err, ShouldBeNil)
+
+		keys := make(map[string]bool)
+		for _, key := range fl.resultKeys() {
+			So(keys, ShouldNotContainKey, key)
+			keys[key] = true
+		}
+		So(keys, ShouldContainKey, "dns.google (8.8.4.4)")
+	})
+}
+
+func TestServerList_FilterTags(t *testing.T) {
+	sl := ServerList{
+		{IP: "127.0.0.1", Tags: []string{"office"}},
+		{IP: "127.0.0.2", Tags: []string{"isp"}},
+		{IP: "127.0.0.3"},
+	}
+
+	Convey("servers with any of the tags are returned", t, func() {
+		fl, err := sl.FilterTags([]string{"office", "isp"})
+		So(err, ShouldBeNil)
+		So(fl, ShouldResemble, ServerList{sl[0], sl[1]})
+	})
+
+	Convey("no matching servers is an error", t, func() {
+		_, err := sl.FilterTags([]string{"home"})
+		So(err, ShouldBeError)
+	})
+}
@@ -0,0 +1,220 @@
+package dnsyo
+
+import (
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	historySize     = 10  // number of test outcomes kept for each server
+	DefaultMinScore = 0.5 // score below which a server is dropped from the list
+)
+
+// HealthCheck is the outcome of a single test of a server
+type HealthCheck struct {
+	Time  time.Time
+	OK    bool
+	RTT   time.Duration `json:",omitempty"`
+	Error string        `json:",omitempty"`
+}
+
+// ServerHealth holds the recent test outcomes of a single server, oldest first
+type ServerHealth struct {
+	Checks      []HealthCheck
+	LastSuccess time.Time
+	MedianRTT   time.Duration
+}
+
+// HealthHistory maps server IPs to their recent health so that a single failed test does not drop a server that has
+// otherwise been reliable.
+type HealthHistory map[string]*ServerHealth
+
+// HistoryFromFile loads the health history from a JSON state file.
+// A missing file is not an error and results in an empty history.
+func HistoryFromFile(filename string) (hh HealthHistory, err error) {
+	hh = make(HealthHistory)
+
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return hh, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &hh)
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// DumpToFile writes the health history to a JSON state file.
+func (hh HealthHistory) DumpToFile(filename string) error {
+	data, err := json.MarshalIndent(hh, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filename, data, 0644)
+}
+
+// Record adds the outcome of a test to the server's history, discarding the oldest outcome if the history is full.
+func (hh HealthHistory) Record(s Server, at time.Time, rtt time.Duration, err error) {
+	h, ok := hh[s.IP]
+	if !ok {
+		h = new(ServerHealth)
+		hh[s.IP] = h
+	}
+
+	c := HealthCheck{
+		Time: at,
+		OK:   err == nil,
+	}
+	if err != nil {
+		c.Error = err.Error()
+	} else {
+		c.RTT = rtt
+		h.LastSuccess = at
+	}
+
+	h.Checks = append(h.Checks, c)
+	if len(h.Checks) > historySize {
+		h.Checks = h.Checks[len(h.Checks)-historySize:]
+	}
+
+	h.MedianRTT = h.medianRTT()
+}
+
+// Prune removes the history of the servers that are not in the list, such as those that have left the upstream list,
+// so that the state file does not keep growing. The number of servers removed is returned.
+func (hh HealthHistory) Prune(sl ServerList) (removed int) {
+	current := make(map[string]bool, len(sl))
+	for _, s := range sl {
+		current[s.IP] = true
+	}
+
+	for ip := range hh {
+		if !current[ip] {
+			delete(hh, ip)
+			removed++
+		}
+	}
+	return
+}
+
+// LastChecked returns the time of the most recent test, or the zero time if the server has never been tested.
+func (h *ServerHealth) LastChecked() time.Time {
+	if len(h.Checks) == 0 {
+		return time.Time{}
+	}
+	return h.Checks[len(h.Checks)-1].Time
+}
+
+// Score rates the server between 0 and 1 based on its recent successes, giving more weight to recent tests.
+// A server with no history scores 0.
+func (h *ServerHealth) Score() float64 {
+	var score, total float64
+	for i, c := range h.Checks {
+		weight := float64(i + 1)
+		total += weight
+		if c.OK {
+			score += weight
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return score / total
+}
+
+// medianRTT calculates the median round trip time of the successful tests in the history.
+func (h *ServerHealth) medianRTT() time.Duration {
+	var rtts []time.Duration
+	for _, c := range h.Checks {
+		if c.OK && c.RTT > 0 {
+			rtts = append(rtts, c.RTT)
+		}
+	}
+
+	if len(rtts) == 0 {
+		return 0
+	}
+
+	sort.Slice(rtts, func(i, j int) bool {
+		return rtts[i] < rtts[j]
+	})
+
+	mid := len(rtts) / 2
+	if len(rtts)%2 == 0 {
+		return (rtts[mid-1] + rtts[mid]) / 2
+	}
+	return rtts[mid]
+}
+
+// reason gives the most recent reason the server failed, for use when it is dropped from the list.
+func (h *ServerHealth) reason() string {
+	if n := len(h.Checks); n > 0 && !h.Checks[n-1].OK {
+		return h.Checks[n-1].Error
+	}
+	return "LOW SCORE"
+}
+
+// TestAllWithHistory tests the servers in the current list, recording the outcomes in the history, and returns the
+// servers whose score is at least minScore. Dropped servers are grouped by the reason they last failed.
+//
+// If staleAfter is greater than 0, servers that have been tested more recently than staleAfter are not tested again
+// and are judged on their existing history.
+func (sl *ServerList) TestAllWithHistory(threads int, hh HealthHistory, minScore float64, staleAfter time.Duration) (working ServerList, failures map[string]ServerList) {
+	failures = make(map[string]ServerList)
+	now := time.Now()
+
+	var stale ServerList
+	for _, s := range *sl {
+		if h, ok := hh[s.IP]; ok && staleAfter > 0 && now.Sub(h.LastChecked()) < staleAfter {
+			continue
+		}
+		stale = append(stale, s)
+	}
+
+	stale.testAll(threads, func(s Server, rtt time.Duration, err error) {
+		hh.Record(s, now, rtt, err)
+	})
+
+	for _, s := range *sl {
+		h := hh[s.IP]
+		if h.Score() >= minScore {
+			working = append(working, s)
+			continue
+		}
+
+		reason := h.reason()
+		log.WithFields(log.Fields{
+			"server": s.String(),
+			"reason": reason,
+			"score":  h.Score(),
+		}).Info("Disabling server")
+
+		failures[reason] = append(failures[reason], s)
+	}
+
+	return working, failures
+}
+
+// SplitRTT separates the servers whose median response time in the history is slower than max from the rest.
+// Pinned servers and servers without a recorded response time are never considered slow.
+func (sl *ServerList) SplitRTT(hh HealthHistory, max time.Duration) (fast, slow ServerList) {
+	for _, s := range *sl {
+		if h, ok := hh[s.IP]; ok && !s.Pinned && h.MedianRTT > max {
+			slow = append(slow, s)
+		} else {
+			fast = append(fast, s)
+		}
+	}
+	return
+}
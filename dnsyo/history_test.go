@@ -0,0 +1,158 @@
+package dnsyo
+
+import (
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	tmpHistoryDump = ".history-test.json"
+)
+
+func TestHealthHistory_Record(t *testing.T) {
+	s := Server{IP: "127.0.0.1"}
+	now := time.Now()
+
+	Convey("outcomes are added to the history", t, func() {
+		hh := make(HealthHistory)
+		hh.Record(s, now, 10*time.Millisecond, nil)
+		hh.Record(s, now.Add(time.Minute), 0, errors.New("TIMEOUT"))
+
+		h := hh[s.IP]
+		So(h.Checks, ShouldHaveLength, 2)
+		So(h.LastSuccess, ShouldEqual, now)
+		So(h.LastChecked(), ShouldEqual, now.Add(time.Minute))
+		So(h.Checks[1].Error, ShouldEqual, "TIMEOUT")
+		So(h.reason(), ShouldEqual, "TIMEOUT")
+	})
+
+	Convey("history is limited in size", t, func() {
+		hh := make(HealthHistory)
+		for i := 0; i < historySize+5; i++ {
+			hh.Record(s, now.Add(time.Duration(i)*time.Minute), time.Millisecond, nil)
+		}
+
+		So(hh[s.IP].Checks, ShouldHaveLength, historySize)
+		So(hh[s.IP].LastChecked(), ShouldEqual, now.Add(time.Duration(historySize+4)*time.Minute))
+	})
+
+	Convey("median rtt only uses successful tests", t, func() {
+		hh := make(HealthHistory)
+		hh.Record(s, now, 30*time.Millisecond, nil)
+		hh.Record(s, now, 10*time.Millisecond, nil)
+		hh.Record(s, now, 0, errors.New("TIMEOUT"))
+		So(hh[s.IP].MedianRTT, ShouldEqual, 20*time.Millisecond)
+
+		hh.Record(s, now, 20*time.Millisecond, nil)
+		So(hh[s.IP].MedianRTT, ShouldEqual, 20*time.Millisecond)
+	})
+}
+
+func TestHealthHistory_Prune(t *testing.T) {
+	Convey("servers that are not in the list are removed", t, func() {
+		now := time.Now()
+		hh := make(HealthHistory)
+		for _, ip := range []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"} {
+			hh.Record(Server{IP: ip}, now, time.Millisecond, nil)
+		}
+
+		So(hh.Prune(ServerList{{IP: "127.0.0.1"}, {IP: "127.0.0.3"}, {IP: "127.0.0.4"}}), ShouldEqual, 1)
+		So(hh, ShouldHaveLength, 2)
+		So(hh, ShouldContainKey, "127.0.0.1")
+		So(hh, ShouldNotContainKey, "127.0.0.2")
+	})
+}
+
+func TestServerHealth_Score(t *testing.T) {
+	ok := HealthCheck{OK: true}
+	bad := HealthCheck{OK: false}
+
+	Convey("no history scores zero", t, func() {
+		So(new(ServerHealth).Score(), ShouldEqual, 0)
+	})
+
+	Convey("recent tests are worth more", t, func() {
+		recovered := &ServerHealth{Checks: []HealthCheck{bad, ok}}
+		failing := &ServerHealth{Checks: []HealthCheck{ok, bad}}
+
+		So(recovered.Score(), ShouldBeGreaterThan, failing.Score())
+	})
+
+	Convey("a single failure does not drop a reliable server", t, func() {
+		h := &ServerHealth{Checks: []HealthCheck{ok, ok, ok, ok, ok, ok, bad}}
+		So(h.Score(), ShouldBeGreaterThanOrEqualTo, DefaultMinScore)
+	})
+}
+
+func TestHealthHistory_DumpToFile(t *testing.T) {
+	hh := make(HealthHistory)
+	hh.Record(Server{IP: "127.0.0.1"}, time.Now().Round(time.Second), 5*time.Millisecond, nil)
+
+	Convey("history can be written and read back", t, func() {
+		err := hh.DumpToFile(tmpHistoryDump)
+		So(err, ShouldBeNil)
+
+		read, err := HistoryFromFile(tmpHistoryDump)
+		So(err, ShouldBeNil)
+		So(read["127.0.0.1"].MedianRTT, ShouldEqual, hh["127.0.0.1"].MedianRTT)
+		So(read["127.0.0.1"].LastSuccess.Equal(hh["127.0.0.1"].LastSuccess), ShouldBeTrue)
+	})
+
+	Convey("a missing file gives an empty history", t, func() {
+		read, err := HistoryFromFile(".does-not-exist.json")
+		So(err, ShouldBeNil)
+		So(read, ShouldBeEmpty)
+	})
+
+	err := os.Remove(tmpHistoryDump)
+	if err != nil {
+		t.Errorf("failed to delete tempory file: %s", err.Error())
+	}
+}
+
+func TestServerList_TestAllWithHistory(t *testing.T) {
+	good := Server{IP: "127.0.0.1", Name: "good"}
+	flaky := Server{IP: "127.0.0.2", Name: "flaky"}
+	dead := Server{IP: "127.0.0.3", Name: "dead"}
+	sl := ServerList{good, flaky, dead}
+
+	now := time.Now()
+	hh := make(HealthHistory)
+	for i := 0; i < 5; i++ {
+		hh.Record(good, now, time.Millisecond, nil)
+		hh.Record(dead, now, 0, errors.New("TIMEOUT"))
+	}
+	hh.Record(flaky, now, time.Millisecond, nil)
+	hh.Record(flaky, now, 0, errors.New("CONNECTION REFUSED"))
+
+	Convey("fresh servers are judged on their history without being tested", t, func() {
+		working, failures := sl.TestAllWithHistory(3, hh, DefaultMinScore, time.Hour)
+		So(working, ShouldResemble, ServerList{good})
+		So(failures["TIMEOUT"], ShouldResemble, ServerList{dead})
+		So(failures["CONNECTION REFUSED"], ShouldResemble, ServerList{flaky})
+		So(hh[good.IP].Checks, ShouldHaveLength, 5)
+	})
+}
+
+func TestServerList_SplitRTT(t *testing.T) {
+	fast := Server{IP: "127.0.0.1"}
+	slow := Server{IP: "127.0.0.2"}
+	pinned := Server{IP: "127.0.0.3", Pinned: true}
+	untested := Server{IP: "127.0.0.4"}
+	sl := ServerList{fast, slow, pinned, untested}
+
+	now := time.Now()
+	hh := make(HealthHistory)
+	hh.Record(fast, now, 10*time.Millisecond, nil)
+	hh.Record(slow, now, 500*time.Millisecond, nil)
+	hh.Record(pinned, now, 500*time.Millisecond, nil)
+
+	Convey("servers slower than the limit are split out", t, func() {
+		f, s := sl.SplitRTT(hh, 100*time.Millisecond)
+		So(f, ShouldResemble, ServerList{fast, pinned, untested})
+		So(s, ShouldResemble, ServerList{slow})
+	})
+}
@@ -0,0 +1,45 @@
+package dnsyo
+
+import (
+	"fmt"
+	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+	"strings"
+)
+
+// idnProfile converts internationalised names for lookup. Unlike idna.Lookup it allows underscores, so that names
+// such as _dmarc.bücher.example can be queried. Processing is non-transitional (IDNA2008), as browsers and registries
+// use, so that ß and ς are kept rather than being mapped to ss and σ, which would be a different name.
+var idnProfile = idna.New(
+	idna.MapForLookup(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false),
+)
+
+// SetDomain checks the domain is a valid name and sets it on the query. Internationalised names are converted to
+// their ASCII (punycode) form for the query, and IDN is set to the Unicode form for display.
+func (q *Query) SetDomain(domain string) error {
+	ascii, err := idnProfile.ToASCII(strings.TrimSpace(domain))
+	if err != nil {
+		return fmt.Errorf("invalid domain %s: %s", domain, err)
+	}
+	if _, ok := dns.IsDomainName(ascii); !ok || ascii == "" {
+		return fmt.Errorf("invalid domain %s", domain)
+	}
+
+	q.Domain = ascii
+	q.IDN = ""
+	if unicode, err := idnProfile.ToUnicode(ascii); err == nil && unicode != ascii {
+		q.IDN = unicode
+	}
+	return nil
+}
+
+// DisplayDomain is the domain to show in summaries, which for internationalised names is the Unicode form followed by
+// the ASCII form that was queried.
+func (q *Query) DisplayDomain() string {
+	if q.IDN != "" {
+		return fmt.Sprintf("%s (%s)", q.IDN, q.Domain)
+	}
+	return q.Domain
+}
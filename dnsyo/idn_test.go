@@ -0,0 +1,54 @@
+package dnsyo
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestQuery_SetDomain(t *testing.T) {
+	Convey("ASCII names are used as they are", t, func() {
+		q := &Query{}
+		So(q.SetDomain("example.com."), ShouldBeNil)
+		So(q.Domain, ShouldEqual, "example.com.")
+		So(q.IDN, ShouldBeEmpty)
+		So(q.DisplayDomain(), ShouldEqual, "example.com.")
+
+		So(q.SetDomain("_dmarc.example.com"), ShouldBeNil)
+		So(q.Domain, ShouldEqual, "_dmarc.example.com")
+	})
+
+	Convey("internationalised names are converted to punycode", t, func() {
+		q := &Query{}
+		So(q.SetDomain("Bücher.example"), ShouldBeNil)
+		So(q.Domain, ShouldEqual, "xn--bcher-kva.example")
+		So(q.IDN, ShouldEqual, "bücher.example")
+		So(q.DisplayDomain(), ShouldEqual, "bücher.example (xn--bcher-kva.example)")
+	})
+
+	Convey("punycode names are displayed in Unicode", t, func() {
+		q := &Query{}
+		So(q.SetDomain("xn--bcher-kva.example"), ShouldBeNil)
+		So(q.IDN, ShouldEqual, "bücher.example")
+	})
+
+	Convey("deviation characters are kept as in IDNA2008", t, func() {
+		q := &Query{}
+		So(q.SetDomain("faß.de"), ShouldBeNil)
+		So(q.Domain, ShouldEqual, "xn--fa-hia.de")
+		So(q.IDN, ShouldEqual, "faß.de")
+
+		So(q.SetDomain("βόλος.com"), ShouldBeNil)
+		So(q.Domain, ShouldEqual, "xn--nxasmm1c.com")
+		So(q.IDN, ShouldEqual, "βόλος.com")
+
+		So(q.SetDomain("xn--nxasmm1c.com"), ShouldBeNil)
+		So(q.IDN, ShouldEqual, "βόλος.com")
+	})
+
+	Convey("invalid names are an error", t, func() {
+		q := &Query{}
+		So(q.SetDomain("xn--a.example"), ShouldBeError)
+		So(q.SetDomain("a..example"), ShouldBeError, "invalid domain a..example")
+		So(q.SetDomain(""), ShouldBeError, "invalid domain ")
+	})
+}
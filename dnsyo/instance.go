@@ -0,0 +1,72 @@
+package dnsyo
+
+import (
+	"encoding/hex"
+	"github.com/miekg/dns"
+	"strings"
+	"unicode"
+)
+
+// chaosNames are the CHAOS TXT names servers use to identify themselves, in the order they are tried.
+var chaosNames = []string{"id.server.", "hostname.bind."}
+
+// requestNSID adds an empty NSID option to a message, asking the server to identify itself.
+func requestNSID(msg *dns.Msg) {
+	opt := edns0(msg)
+	opt.Option = append(opt.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+}
+
+// responseNSID finds the NSID the server returned in a response, or an empty string if it did not return one.
+// NSIDs that are not printable text are shown in hex.
+func responseNSID(resp *dns.Msg) string {
+	opt := resp.IsEdns0()
+	if opt == nil {
+		return ""
+	}
+
+	for _, o := range opt.Option {
+		nsid, ok := o.(*dns.EDNS0_NSID)
+		if !ok || nsid.Nsid == "" {
+			continue
+		}
+
+		raw, err := hex.DecodeString(nsid.Nsid)
+		if err != nil {
+			return nsid.Nsid
+		}
+		for _, r := range string(raw) {
+			if !unicode.IsPrint(r) {
+				return nsid.Nsid
+			}
+		}
+		return string(raw)
+	}
+	return ""
+}
+
+// chaosIdentity asks the server for its identity with the CHAOS TXT queries used by most nameservers.
+// Returns an empty string if the server does not answer either of them. These are separate queries, so behind an
+// anycast address they may reach a different instance to the one that answered the lookup.
+func (s *Server) chaosIdentity() string {
+	return s.chaosTXT(chaosNames...)
+}
+
+// chaosTXT asks the server for each of the CHAOS TXT names in turn, returning the first answer given.
+// Returns an empty string if the server does not answer any of them.
+func (s *Server) chaosTXT(names ...string) string {
+	for _, name := range names {
+		msg := newQuestion(name, dns.TypeTXT, false)
+		msg.Question[0].Qclass = dns.ClassCHAOS
+
+		resp, _, err := s.send(msg)
+		if err != nil {
+			continue
+		}
+		for _, rr := range resp.Answer {
+			if txt, ok := rr.(*dns.TXT); ok && len(txt.Txt) > 0 {
+				return strings.Join(txt.Txt, "")
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package dnsyo
+
+import (
+	"encoding/hex"
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestNSID(t *testing.T) {
+	Convey("NSID is requested when asked for", t, func() {
+		q := &Query{Domain: "example.test", Type: dns.TypeA}
+		So(q.message().IsEdns0(), ShouldBeNil)
+
+		q.NSID = true
+		opt := q.message().IsEdns0()
+		So(opt, ShouldNotBeNil)
+		So(opt.Option[0].Option(), ShouldEqual, dns.EDNS0NSID)
+	})
+
+	Convey("the NSID is read from the response", t, func() {
+		resp := new(dns.Msg)
+		So(responseNSID(resp), ShouldBeEmpty)
+
+		resp.SetEdns0(4096, false)
+		opt := resp.IsEdns0()
+		nsid := &dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: hex.EncodeToString([]byte("gpdns-lhr"))}
+		opt.Option = append(opt.Option, nsid)
+		So(responseNSID(resp), ShouldEqual, "gpdns-lhr")
+
+		nsid.Nsid = "00ff10"
+		So(responseNSID(resp), ShouldEqual, "00ff10")
+	})
+}
+
+func TestQuery_GroupByInstance(t *testing.T) {
+	q := &Query{
+		Results: QueryResults{
+			"google-a": &Result{Answer: "192.0.2.1", Instance: "gpdns-lhr"},
+			"google-b": &Result{Answer: "192.0.2.2", Instance: "gpdns-ams"},
+			"google-c": &Result{Answer: "192.0.2.1", Instance: "gpdns-lhr"},
+			"google-d": &Result{Answer: "192.0.2.1"},
+			"other":    &Result{Error: "TIMEOUT"},
+		},
+	}
+
+	Convey("grouping by instance asks for the NSID", t, func() {
+		So(q.SetGroupBy([]string{"Instance"}), ShouldBeNil)
+		So(q.GroupBy, ShouldResemble, []string{GroupByInstance})
+		So(q.NSID, ShouldBeTrue)
+	})
+
+	Convey("the summary groups the answers by instance", t, func() {
+		text := q.ToTextSummary()
+		So(text, ShouldContainSubstring, "2 servers responded with;\n192.0.2.1\ninstance: gpdns-lhr\n\n")
+		So(text, ShouldContainSubstring, "1 servers responded with;\n192.0.2.2\ninstance: gpdns-ams\n\n")
+		So(text, ShouldContainSubstring, "1 servers responded with;\n192.0.2.1\ninstance: unknown\n\n")
+		So(text, ShouldContainSubstring, "1 servers responded with;\nTIMEOUT\ninstance: unknown\n\n")
+	})
+}
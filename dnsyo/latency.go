@@ -0,0 +1,143 @@
+package dnsyo
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// LatencySummary describes the distribution of response times for a group of servers
+type LatencySummary struct {
+	Count         int
+	P50, P90, P99 time.Duration
+}
+
+// summariseLatency calculates the percentiles of the given response times using the nearest rank method.
+func summariseLatency(rtts []time.Duration) (ls LatencySummary) {
+	ls.Count = len(rtts)
+	if ls.Count == 0 {
+		return
+	}
+
+	sorted := append([]time.Duration{}, rtts...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	rank := func(p int) time.Duration {
+		i := (p*len(sorted)+99)/100 - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+
+	ls.P50, ls.P90, ls.P99 = rank(50), rank(90), rank(99)
+	return
+}
+
+// String formats the summary for use in the text summary.
+func (ls LatencySummary) String() string {
+	return fmt.Sprintf("p50 %s\tp90 %s\tp99 %s", formatRTT(ls.P50), formatRTT(ls.P90), formatRTT(ls.P99))
+}
+
+// formatRTT formats a response time in milliseconds, which is easier to compare by eye than time.Duration's String.
+func formatRTT(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+// Latency summarises the response times of the servers that responded, both overall and by country.
+// Servers that did not respond are not included.
+func (qr QueryResults) Latency() (overall LatencySummary, byCountry map[string]LatencySummary) {
+	var all []time.Duration
+	countries := make(map[string][]time.Duration)
+
+	for _, r := range qr {
+		if r.RTT <= 0 {
+			continue
+		}
+		all = append(all, r.RTT)
+		countries[r.Country] = append(countries[r.Country], r.RTT)
+	}
+
+	byCountry = make(map[string]LatencySummary)
+	for c, rtts := range countries {
+		byCountry[c] = summariseLatency(rtts)
+	}
+
+	return summariseLatency(all), byCountry
+}
+
+// FilterRTT returns the results from the servers that responded within max.
+// Servers that did not respond at all are also removed.
+func (qr QueryResults) FilterRTT(max time.Duration) QueryResults {
+	fr := make(QueryResults)
+	for name, r := range qr {
+		if r.RTT > 0 && r.RTT <= max {
+			fr[name] = r
+		}
+	}
+	return fr
+}
+
+// ByLatency returns the names of the servers that responded, fastest first.
+func (qr QueryResults) ByLatency() (names []string) {
+	for name, r := range qr {
+		if r.RTT > 0 {
+			names = append(names, name)
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if qr[names[i]].RTT != qr[names[j]].RTT {
+			return qr[names[i]].RTT < qr[names[j]].RTT
+		}
+		return names[i] < names[j]
+	})
+	return
+}
+
+// ToLatencyTable lists the response time of each server that responded, fastest first, for comparing servers.
+func (q *Query) ToLatencyTable() (text string) {
+	text = "\n - RESPONSE TIMES\n\n"
+	for _, name := range q.Results.ByLatency() {
+		r := q.Results[name]
+		result := r.Answer
+		if r.Error != "" {
+			result = r.Error
+		}
+		if len(result) > 40 {
+			result = result[:37] + "..."
+		}
+		text += fmt.Sprintf("%10s  %-2s  %s  %q\n", formatRTT(r.RTT), r.Country, name, result)
+	}
+	return text
+}
+
+// latencyTextSummary produces the response time section of Query.ToTextSummary, or an empty string if none of the
+// servers responded.
+func (qr QueryResults) latencyTextSummary() (text string) {
+	overall, byCountry := qr.Latency()
+	if overall.Count == 0 {
+		return ""
+	}
+
+	text = fmt.Sprint("\nAnd here are the response times;\n\n")
+	text += fmt.Sprintf("%d servers responded;\n%s\n\n", overall.Count, overall)
+
+	countries := make([]string, 0, len(byCountry))
+	for c := range byCountry {
+		countries = append(countries, c)
+	}
+	sort.Strings(countries)
+
+	for _, c := range countries {
+		name := c
+		if name == "" {
+			name = "UNKNOWN"
+		}
+		text += fmt.Sprintf("%s\t%d servers\t%s\n", name, byCountry[c].Count, byCountry[c])
+	}
+
+	return text
+}
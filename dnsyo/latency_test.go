@@ -0,0 +1,72 @@
+package dnsyo
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestSummariseLatency(t *testing.T) {
+	Convey("no response times gives an empty summary", t, func() {
+		So(summariseLatency(nil), ShouldResemble, LatencySummary{})
+	})
+
+	Convey("percentiles use the nearest rank", t, func() {
+		var rtts []time.Duration
+		for i := 100; i > 0; i-- {
+			rtts = append(rtts, time.Duration(i)*time.Millisecond)
+		}
+
+		ls := summariseLatency(rtts)
+		So(ls.Count, ShouldEqual, 100)
+		So(ls.P50, ShouldEqual, 50*time.Millisecond)
+		So(ls.P90, ShouldEqual, 90*time.Millisecond)
+		So(ls.P99, ShouldEqual, 99*time.Millisecond)
+		So(rtts[0], ShouldEqual, 100*time.Millisecond)
+	})
+
+	Convey("a single response is every percentile", t, func() {
+		ls := summariseLatency([]time.Duration{time.Millisecond})
+		So(ls, ShouldResemble, LatencySummary{1, time.Millisecond, time.Millisecond, time.Millisecond})
+		So(ls.String(), ShouldEqual, "p50 1.0ms\tp90 1.0ms\tp99 1.0ms")
+	})
+}
+
+func TestQueryResults_Latency(t *testing.T) {
+	qr := QueryResults{
+		"a": &Result{Answer: "1234", RTT: 10 * time.Millisecond, Country: "GB"},
+		"b": &Result{Answer: "1234", RTT: 30 * time.Millisecond, Country: "GB"},
+		"c": &Result{Error: "SERVFAIL", RTT: 20 * time.Millisecond, Country: "US"},
+		"d": &Result{Error: "TIMEOUT", Country: "US"},
+	}
+
+	Convey("servers that responded are summarised overall and by country", t, func() {
+		overall, byCountry := qr.Latency()
+		So(overall.Count, ShouldEqual, 3)
+		So(overall.P50, ShouldEqual, 20*time.Millisecond)
+		So(byCountry["GB"].Count, ShouldEqual, 2)
+		So(byCountry["US"].P99, ShouldEqual, 20*time.Millisecond)
+	})
+
+	Convey("filtering removes slow servers and those that did not respond", t, func() {
+		fr := qr.FilterRTT(20 * time.Millisecond)
+		So(fr, ShouldHaveLength, 2)
+		So(fr, ShouldContainKey, "a")
+		So(fr, ShouldContainKey, "c")
+	})
+
+	Convey("servers are ordered fastest first", t, func() {
+		So(qr.ByLatency(), ShouldResemble, []string{"a", "c", "b"})
+	})
+
+	Convey("summary and table", t, func() {
+		q := &Query{Domain: "example.test", Results: qr}
+
+		text := q.ToTextSummary()
+		So(text, ShouldContainSubstring, "\nAnd here are the response times;\n\n3 servers responded;\np50 20.0ms\tp90 30.0ms\tp99 30.0ms\n\n")
+		So(text, ShouldContainSubstring, "GB\t2 servers\tp50 10.0ms")
+
+		table := q.ToLatencyTable()
+		So(table, ShouldContainSubstring, "    10.0ms  GB  a  \"1234\"\n    20.0ms  US  c  \"SERVFAIL\"\n")
+	})
+}
@@ -0,0 +1,423 @@
+package dnsyo
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/miekg/dns"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of email authentication policy checked by MailCheck
+const (
+	MailSPF    = "SPF"
+	MailDMARC  = "DMARC"
+	MailDKIM   = "DKIM"
+	MailMTASTS = "MTA-STS"
+)
+
+// maxSPFLookups is the number of terms causing DNS lookups an SPF record may have, from RFC 7208 section 4.6.4.
+const maxSPFLookups = 10
+
+// mtaSTSID matches the policy id of an MTA-STS record, from RFC 8461 section 3.1.
+var mtaSTSID = regexp.MustCompile(`^[A-Za-z0-9]{1,32}$`)
+
+// MailPolicy is an email authentication policy parsed from a TXT record.
+type MailPolicy struct {
+	Kind string
+
+	// Record is the policy with its whitespace normalised, which is used to group servers with the same policy
+	Record string
+
+	// Tags are the tags of DMARC, DKIM and MTA-STS records, and Terms the mechanisms and modifiers of SPF records
+	Tags  map[string]string `json:",omitempty"`
+	Terms []string          `json:",omitempty"`
+
+	// Problems are syntax errors found in the policy
+	Problems []string `json:",omitempty"`
+}
+
+// MailPolicyGroup is a policy, or the error given instead, and the number of servers that gave it.
+type MailPolicyGroup struct {
+	Policy  *MailPolicy `json:",omitempty"`
+	Error   string      `json:",omitempty"`
+	Servers int
+}
+
+// MailCheck queries the TXT records used to authenticate email for a domain.
+type MailCheck struct {
+	Domain    string
+	Selectors []string
+	Batch     *Batch
+
+	// kinds is the kind of policy each query in the batch is for
+	kinds []string
+}
+
+// NewMailCheck creates the queries for the SPF, DMARC and MTA-STS policies of a domain, and its DKIM keys for each of
+// the selectors.
+func NewMailCheck(domain string, selectors []string) (*MailCheck, error) {
+	mc := &MailCheck{Selectors: selectors, Batch: &Batch{}}
+
+	q := &Query{}
+	if err := q.SetDomain(domain); err != nil {
+		return nil, err
+	}
+	mc.Domain = dns.Fqdn(q.Domain)
+
+	names := []string{mc.Domain, "_dmarc." + mc.Domain}
+	kinds := []string{MailSPF, MailDMARC}
+	for _, selector := range selectors {
+		names = append(names, selector+"._domainkey."+mc.Domain)
+		kinds = append(kinds, MailDKIM)
+	}
+	names = append(names, "_mta-sts."+mc.Domain)
+	kinds = append(kinds, MailMTASTS)
+
+	for i, name := range names {
+		added := len(mc.Batch.Queries)
+		if err := mc.Batch.Add(Query{}, name, "TXT"); err != nil {
+			return nil, err
+		}
+		// repeated selectors are only queried once
+		if len(mc.Batch.Queries) > added {
+			mc.kinds = append(mc.kinds, kinds[i])
+		}
+	}
+	return mc, nil
+}
+
+// txtStrings splits the text of a TXT record into its strings, removing the quotes and escapes.
+func txtStrings(text string) (strs []string) {
+	var current []byte
+	var quoted bool
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '"':
+			if quoted {
+				strs = append(strs, string(current))
+				current = nil
+			}
+			quoted = !quoted
+
+		case c == '\\' && i+3 < len(text) && isDigits(text[i+1:i+4]):
+			n, _ := strconv.Atoi(text[i+1 : i+4])
+			current = append(current, byte(n))
+			i += 3
+
+		case c == '\\' && i+1 < len(text):
+			i++
+			current = append(current, text[i])
+
+		case quoted:
+			current = append(current, c)
+		}
+	}
+	return
+}
+
+// isDigits checks if a string only contains decimal digits.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// mailRecords finds the TXT records of a kind of policy in an answer, joining the strings of each record.
+func mailRecords(kind, answer string) (records []string) {
+	for _, line := range strings.Split(answer, "\n") {
+		record := strings.Join(txtStrings(line), "")
+		lower := strings.ToLower(record)
+		switch kind {
+		case MailSPF:
+			if lower == "v=spf1" || strings.HasPrefix(lower, "v=spf1 ") {
+				records = append(records, record)
+			}
+		case MailDMARC:
+			if strings.HasPrefix(lower, "v=dmarc1") {
+				records = append(records, record)
+			}
+		case MailMTASTS:
+			if strings.HasPrefix(lower, "v=stsv1") {
+				records = append(records, record)
+			}
+		case MailDKIM:
+			// the version tag is optional for DKIM keys
+			if strings.HasPrefix(lower, "v=dkim1") || strings.Contains(lower, "p=") {
+				records = append(records, record)
+			}
+		}
+	}
+	return
+}
+
+// ParseMailPolicy parses the policy of a kind from the TXT records in an answer, or returns nil if there is no record
+// for the policy. Only one record is allowed, if there are more they are reported as a problem and the first in
+// sorted order is parsed so that servers returning them in a different order are grouped together.
+func ParseMailPolicy(kind, answer string) *MailPolicy {
+	records := mailRecords(kind, answer)
+	if len(records) == 0 {
+		return nil
+	}
+	sort.Strings(records)
+
+	var p *MailPolicy
+	if kind == MailSPF {
+		p = parseSPF(records[0])
+	} else {
+		p = parseTags(kind, records[0])
+	}
+	if len(records) > 1 {
+		p.Problems = append(p.Problems, fmt.Sprintf("%d %s records, only one is allowed", len(records), kind))
+	}
+	return p
+}
+
+// parseSPF parses and checks an SPF record.
+func parseSPF(record string) *MailPolicy {
+	p := &MailPolicy{Kind: MailSPF, Terms: strings.Fields(record)}
+	p.Record = strings.Join(p.Terms, " ")
+
+	var lookups int
+	modifiers := make(map[string]bool)
+	for _, term := range p.Terms[1:] {
+		if i := strings.Index(term, "="); i > 0 && !strings.ContainsAny(term[:i], ":/") {
+			name := strings.ToLower(term[:i])
+			switch {
+			case modifiers[name] && (name == "redirect" || name == "exp"):
+				p.Problems = append(p.Problems, fmt.Sprintf("the %s modifier is repeated", name))
+			case name == "redirect":
+				lookups++
+			}
+			modifiers[name] = true
+			continue
+		}
+
+		mechanism := strings.ToLower(strings.TrimLeft(term, "+-~?"))
+		if i := strings.IndexAny(mechanism, ":/"); i >= 0 {
+			mechanism = mechanism[:i]
+		}
+		switch mechanism {
+		case "include", "a", "mx", "ptr", "exists":
+			lookups++
+		case "all", "ip4", "ip6":
+		default:
+			p.Problems = append(p.Problems, fmt.Sprintf("unknown mechanism %s", term))
+		}
+	}
+
+	if lookups > maxSPFLookups {
+		p.Problems = append(p.Problems, fmt.Sprintf("%d terms need DNS lookups, the limit is %d", lookups, maxSPFLookups))
+	}
+	return p
+}
+
+// parseTags parses and checks a DMARC, DKIM or MTA-STS record, which are lists of tag=value separated by semicolons.
+func parseTags(kind, record string) *MailPolicy {
+	p := &MailPolicy{Kind: kind, Tags: make(map[string]string)}
+
+	var normalised, order []string
+	for _, spec := range strings.Split(record, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		i := strings.Index(spec, "=")
+		if i <= 0 {
+			p.Problems = append(p.Problems, fmt.Sprintf("invalid tag %q", spec))
+			continue
+		}
+		name, value := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+		if _, ok := p.Tags[name]; ok {
+			p.Problems = append(p.Problems, fmt.Sprintf("the %s tag is repeated", name))
+		}
+		p.Tags[name] = value
+		order = append(order, name)
+		normalised = append(normalised, name+"="+value)
+	}
+	p.Record = strings.Join(normalised, "; ")
+
+	if len(order) > 0 && order[0] != "v" && (kind != MailDKIM || p.Tags["v"] != "") {
+		p.Problems = append(p.Problems, "the v tag must be first")
+	}
+
+	switch kind {
+	case MailDMARC:
+		p.checkDMARC()
+	case MailDKIM:
+		p.checkDKIM()
+	case MailMTASTS:
+		if !mtaSTSID.MatchString(p.Tags["id"]) {
+			p.Problems = append(p.Problems, "the id tag must be 1 to 32 letters and digits")
+		}
+	}
+	return p
+}
+
+// checkDMARC checks the tags of a DMARC record, from RFC 7489 section 6.3.
+func (p *MailPolicy) checkDMARC() {
+	policies := map[string]bool{"none": true, "quarantine": true, "reject": true}
+	if policy, ok := p.Tags["p"]; !ok {
+		p.Problems = append(p.Problems, "the p tag is required")
+	} else if !policies[policy] {
+		p.Problems = append(p.Problems, fmt.Sprintf("invalid policy p=%s", policy))
+	}
+	if sp, ok := p.Tags["sp"]; ok && !policies[sp] {
+		p.Problems = append(p.Problems, fmt.Sprintf("invalid subdomain policy sp=%s", sp))
+	}
+
+	for _, tag := range []string{"adkim", "aspf"} {
+		if v, ok := p.Tags[tag]; ok && v != "r" && v != "s" {
+			p.Problems = append(p.Problems, fmt.Sprintf("invalid alignment %s=%s", tag, v))
+		}
+	}
+
+	if pct, ok := p.Tags["pct"]; ok {
+		if n, err := strconv.Atoi(pct); err != nil || n < 0 || n > 100 {
+			p.Problems = append(p.Problems, fmt.Sprintf("invalid percentage pct=%s", pct))
+		}
+	}
+
+	for _, tag := range []string{"rua", "ruf"} {
+		if uris, ok := p.Tags[tag]; ok {
+			for _, uri := range strings.Split(uris, ",") {
+				if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(uri)), "mailto:") {
+					p.Problems = append(p.Problems, fmt.Sprintf("%s address %s is not a mailto URI", tag, uri))
+				}
+			}
+		}
+	}
+}
+
+// checkDKIM checks the tags of a DKIM key record, from RFC 6376 section 3.6.1.
+func (p *MailPolicy) checkDKIM() {
+	if v, ok := p.Tags["v"]; ok && v != "DKIM1" {
+		p.Problems = append(p.Problems, fmt.Sprintf("invalid version v=%s", v))
+	}
+	if k, ok := p.Tags["k"]; ok && k != "rsa" && k != "ed25519" {
+		p.Problems = append(p.Problems, fmt.Sprintf("unknown key type k=%s", k))
+	}
+	if key, ok := p.Tags["p"]; !ok {
+		p.Problems = append(p.Problems, "the p tag is required")
+	} else if key == "" {
+		p.Problems = append(p.Problems, "the key has been revoked")
+	}
+}
+
+// String is the record followed by any problems, used to group the servers.
+func (p *MailPolicy) String() string {
+	text := p.Record
+	for _, problem := range p.Problems {
+		text += "\nproblem: " + problem
+	}
+	return text
+}
+
+// groups groups the servers by the policy they gave for one of the queries in the batch, most common first.
+func (mc *MailCheck) groups(i int) (groups []MailPolicyGroup) {
+	q, kind := mc.Batch.Queries[i], mc.kinds[i]
+
+	byKey := make(map[string]*MailPolicyGroup)
+	var keys []string
+	for _, r := range q.Results {
+		g := MailPolicyGroup{}
+		var key string
+		switch {
+		case r.Error != "" && r.ErrorCode != CodeNoAnswer && r.Rcode != dns.RcodeNameError:
+			g.Error, key = r.Error, r.Error
+		default:
+			g.Policy = ParseMailPolicy(kind, r.Answer)
+			if g.Policy == nil {
+				g.Error = fmt.Sprintf("no %s record", kind)
+				key = g.Error
+			} else {
+				key = g.Policy.String()
+			}
+		}
+
+		if existing, ok := byKey[key]; ok {
+			existing.Servers++
+			continue
+		}
+		g.Servers = 1
+		byKey[key] = &g
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if byKey[keys[i]].Servers != byKey[keys[j]].Servers {
+			return byKey[keys[i]].Servers > byKey[keys[j]].Servers
+		}
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys {
+		groups = append(groups, *byKey[key])
+	}
+	return
+}
+
+// Problems lists the syntax errors in the policies the servers gave.
+func (mc *MailCheck) Problems() (problems []string) {
+	seen := make(map[string]bool)
+	for i, q := range mc.Batch.Queries {
+		for _, g := range mc.groups(i) {
+			if g.Policy == nil {
+				continue
+			}
+			for _, problem := range g.Policy.Problems {
+				text := fmt.Sprintf("%s %s: %s", g.Policy.Kind, q.DisplayDomain(), problem)
+				if !seen[text] {
+					seen[text] = true
+					problems = append(problems, text)
+				}
+			}
+		}
+	}
+	return
+}
+
+// ToTextSummary prints the servers grouped by the policy they gave for each of the records checked.
+func (mc *MailCheck) ToTextSummary() (text string) {
+	for i, q := range mc.Batch.Queries {
+		text += fmt.Sprintf("\n - %s %s\nI asked %d servers for the %s record at %s\n\n",
+			mc.kinds[i], q.DisplayDomain(), len(q.Results), mc.kinds[i], q.DisplayDomain())
+
+		for _, g := range mc.groups(i) {
+			if g.Policy != nil {
+				text += fmt.Sprintf("%d servers responded with;\n%s\n\n", g.Servers, g.Policy)
+			} else {
+				text += fmt.Sprintf("%d servers responded with;\n%s\n\n", g.Servers, g.Error)
+			}
+		}
+	}
+	return text
+}
+
+// ToJSON prints the policy groups for each record checked, along with the results from each server.
+func (mc *MailCheck) ToJSON() (string, error) {
+	type check struct {
+		Kind     string
+		Domain   string
+		Policies []MailPolicyGroup
+		Results  QueryResults
+	}
+
+	var checks []check
+	for i, q := range mc.Batch.Queries {
+		checks = append(checks, check{mc.kinds[i], q.Domain, mc.groups(i), q.Results})
+	}
+
+	text, err := json.Marshal(struct {
+		Domain    string
+		Selectors []string `json:",omitempty"`
+		Checks    []check
+		Problems  []string
+	}{mc.Domain, mc.Selectors, checks, mc.Problems()})
+	return string(text), err
+}
@@ -0,0 +1,120 @@
+package dnsyo
+
+import (
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestNewMailCheck(t *testing.T) {
+	Convey("each record is queried once", t, func() {
+		mc, err := NewMailCheck("example.com", []string{"s1", "s2", "s1"})
+		So(err, ShouldBeNil)
+		So(mc.Batch.Queries, ShouldHaveLength, 5)
+		So(mc.Batch.Queries[1].Domain, ShouldEqual, "_dmarc.example.com.")
+		So(mc.Batch.Queries[3].Domain, ShouldEqual, "s2._domainkey.example.com.")
+		So(mc.Batch.Queries[4].Domain, ShouldEqual, "_mta-sts.example.com.")
+		So(mc.Batch.Queries[4].Type, ShouldEqual, dns.TypeTXT)
+		So(mc.kinds, ShouldResemble, []string{MailSPF, MailDMARC, MailDKIM, MailDKIM, MailMTASTS})
+	})
+}
+
+func TestTxtStrings(t *testing.T) {
+	Convey("strings are unquoted and unescaped", t, func() {
+		So(txtStrings(`"v=spf1 include:a" " ~all"`), ShouldResemble, []string{"v=spf1 include:a", " ~all"})
+		So(txtStrings(`"say \"hi\"\059"`), ShouldResemble, []string{`say "hi";`})
+	})
+}
+
+func TestParseMailPolicy(t *testing.T) {
+	Convey("SPF records are split into terms", t, func() {
+		p := ParseMailPolicy(MailSPF, `"google-site-verification=abc"`+"\n"+`"v=spf1  include:_spf.example.net" " -all"`)
+		So(p.Record, ShouldEqual, "v=spf1 include:_spf.example.net -all")
+		So(p.Terms, ShouldResemble, []string{"v=spf1", "include:_spf.example.net", "-all"})
+		So(p.Problems, ShouldBeEmpty)
+	})
+
+	Convey("SPF problems are found", t, func() {
+		p := ParseMailPolicy(MailSPF, `"v=spf1 a mx include:a include:b include:c include:d include:e include:f `+
+			`include:g exists:h redirect=i bogus:j"`+"\n"+`"v=spf1 ~all"`)
+		So(p.Problems, ShouldResemble, []string{
+			"unknown mechanism bogus:j",
+			"11 terms need DNS lookups, the limit is 10",
+			"2 SPF records, only one is allowed",
+		})
+	})
+
+	Convey("there is no policy without a record", t, func() {
+		So(ParseMailPolicy(MailSPF, `"v=spf10"`), ShouldBeNil)
+		So(ParseMailPolicy(MailDMARC, ""), ShouldBeNil)
+	})
+
+	Convey("DMARC records are parsed into tags", t, func() {
+		p := ParseMailPolicy(MailDMARC, `"v=DMARC1;p=reject; rua=mailto:dmarc@example.com"`)
+		So(p.Record, ShouldEqual, "v=DMARC1; p=reject; rua=mailto:dmarc@example.com")
+		So(p.Tags["p"], ShouldEqual, "reject")
+		So(p.Problems, ShouldBeEmpty)
+
+		p = ParseMailPolicy(MailDMARC, `"v=DMARC1; p=block; pct=150; adkim=x; ruf=https://example.com"`)
+		So(p.Problems, ShouldResemble, []string{
+			"invalid policy p=block",
+			"invalid alignment adkim=x",
+			"invalid percentage pct=150",
+			"ruf address https://example.com is not a mailto URI",
+		})
+	})
+
+	Convey("DKIM keys are checked", t, func() {
+		So(ParseMailPolicy(MailDKIM, `"k=rsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQ"`).Problems, ShouldBeEmpty)
+		So(ParseMailPolicy(MailDKIM, `"v=DKIM1; k=dsa; p="`).Problems, ShouldResemble, []string{
+			"unknown key type k=dsa",
+			"the key has been revoked",
+		})
+		So(ParseMailPolicy(MailDKIM, `"k=rsa; v=DKIM1; p=abc"`).Problems, ShouldResemble, []string{
+			"the v tag must be first",
+		})
+	})
+
+	Convey("MTA-STS records need an id", t, func() {
+		So(ParseMailPolicy(MailMTASTS, `"v=STSv1; id=20240101T000000"`).Problems, ShouldBeEmpty)
+		So(ParseMailPolicy(MailMTASTS, `"v=STSv1;"`).Problems, ShouldResemble, []string{
+			"the id tag must be 1 to 32 letters and digits",
+		})
+	})
+}
+
+func TestMailCheck_ToTextSummary(t *testing.T) {
+	mc, _ := NewMailCheck("example.com", nil)
+	mc.Batch.Queries[0].Results = QueryResults{
+		"a": &Result{Answer: `"v=spf1 include:new.example.net -all"`},
+		"b": &Result{Answer: `"v=spf1 include:new.example.net  -all"`},
+		"c": &Result{Answer: `"v=spf1 include:old.example.net -all"`},
+		"d": &Result{Error: "TIMEOUT", ErrorCode: CodeTimeout},
+	}
+	mc.Batch.Queries[1].Results = QueryResults{
+		"a": &Result{Answer: `"v=DMARC1; p=maybe"`},
+	}
+	mc.Batch.Queries[2].Results = QueryResults{
+		"a": &Result{Error: "NXDOMAIN", ErrorCode: CodeRcode, Rcode: dns.RcodeNameError},
+	}
+
+	Convey("servers are grouped by the policy they see", t, func() {
+		text := mc.ToTextSummary()
+		So(text, ShouldContainSubstring, " - SPF example.com.\nI asked 4 servers for the SPF record at example.com.\n\n"+
+			"2 servers responded with;\nv=spf1 include:new.example.net -all\n\n"+
+			"1 servers responded with;\nTIMEOUT\n\n"+
+			"1 servers responded with;\nv=spf1 include:old.example.net -all\n\n")
+		So(text, ShouldContainSubstring, "1 servers responded with;\nv=DMARC1; p=maybe\nproblem: invalid policy p=maybe\n\n")
+		So(text, ShouldContainSubstring, "1 servers responded with;\nno MTA-STS record\n\n")
+	})
+
+	Convey("problems are listed once", t, func() {
+		So(mc.Problems(), ShouldResemble, []string{"DMARC _dmarc.example.com.: invalid policy p=maybe"})
+	})
+
+	Convey("the JSON contains each check", t, func() {
+		text, err := mc.ToJSON()
+		So(err, ShouldBeNil)
+		So(text, ShouldContainSubstring, `{"Kind":"DMARC","Domain":"_dmarc.example.com.","Policies":[{"Policy":{"Kind":"DMARC"`)
+	})
+}
@@ -0,0 +1,309 @@
+package dnsyo
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/miekg/dns"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ttlBuckets are the upper bounds of the remaining cache time histogram. Anything longer goes in a final open bucket.
+var ttlBuckets = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+}
+
+// Statuses given to results by Query.Classify
+const (
+	StatusCurrent   = "current"
+	StatusStale     = "stale"
+	StatusDivergent = "divergent"
+)
+
+// AnswerGroup is a distinct cacheable result and the number of servers that gave it.
+type AnswerGroup struct {
+	Answer  string
+	Servers int
+
+	// MaxTTL is the longest time any of the servers will keep the result cached for
+	MaxTTL time.Duration
+
+	// Current is set if this is the result the other servers are expected to change to
+	Current bool `json:",omitempty"`
+
+	// Divergent is the number of the servers caching the result for longer than the authoritative servers allow
+	Divergent int `json:",omitempty"`
+}
+
+// TTLBucket counts the servers whose cached result expires within Max, but not within the previous bucket's Max.
+// The final bucket has a Max of 0 and counts everything longer than the last bound.
+type TTLBucket struct {
+	Max     time.Duration
+	Servers int
+}
+
+// Propagation estimates when a change will have reached all of the servers, based on how long each server will keep
+// caching the result it gave.
+type Propagation struct {
+	Current string
+	Groups  []AnswerGroup
+
+	// Propagated, Stale and Divergent are the number of servers with each status, see Query.Classify
+	Propagated, Stale, Divergent int
+
+	// Percent is the percentage of the classified servers that have the current result
+	Percent float64
+
+	// ETA is the time until the last stale result expires
+	ETA time.Duration
+
+	// Histogram is the remaining cache time of the stale results
+	Histogram []TTLBucket
+}
+
+// cacheTTL finds how long a server will cache a response for; the lowest TTL of the answer records, or the negative
+// caching time from the SOA record for NXDOMAIN and empty answers. Returns 0 for responses that are not cached.
+func cacheTTL(resp *dns.Msg) uint32 {
+	if resp == nil {
+		return 0
+	}
+
+	if resp.Rcode == dns.RcodeSuccess && len(resp.Answer) > 0 {
+		ttl := resp.Answer[0].Header().Ttl
+		for _, rr := range resp.Answer[1:] {
+			if rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+			}
+		}
+		return ttl
+	}
+
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return 0
+	}
+
+	for _, rr := range resp.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			if soa.Minttl < soa.Hdr.Ttl {
+				return soa.Minttl
+			}
+			return soa.Hdr.Ttl
+		}
+	}
+	return 0
+}
+
+// normaliseAnswer sorts the records in an answer so that servers returning the same records in a different order
+// are grouped together.
+func normaliseAnswer(answer string) string {
+	records := strings.Split(answer, "\n")
+	sort.Strings(records)
+	return strings.Join(records, "\n")
+}
+
+// Propagation groups the results that servers will cache and estimates when the servers with a result other than
+// the current one will pick up the change.
+// The current result is q.Expected, or the most common result if that is not set. Servers that failed in a way that
+// is not cached, such as a timeout or SERVFAIL, are not included.
+func (q *Query) Propagation() *Propagation {
+	p := &Propagation{}
+	groups := make(map[string]*AnswerGroup)
+
+	for _, r := range q.Results {
+		answer, ok := q.cachedAnswer(r)
+		if !ok {
+			continue
+		}
+
+		g, ok := groups[answer]
+		if !ok {
+			g = &AnswerGroup{Answer: answer}
+			groups[answer] = g
+		}
+
+		ttl := time.Duration(r.TTL) * time.Second
+		g.Servers++
+		if ttl > g.MaxTTL {
+			g.MaxTTL = ttl
+		}
+	}
+
+	if q.Expected != "" {
+		p.Current = normaliseAnswer(q.Expected)
+	} else {
+		for _, g := range groups {
+			if p.Current == "" || g.Servers > groups[p.Current].Servers ||
+				g.Servers == groups[p.Current].Servers && g.Answer < p.Current {
+				p.Current = g.Answer
+			}
+		}
+	}
+
+	p.Histogram = make([]TTLBucket, len(ttlBuckets)+1)
+	for i, max := range ttlBuckets {
+		p.Histogram[i].Max = max
+	}
+
+	for _, r := range q.Results {
+		ttl := time.Duration(r.TTL) * time.Second
+		switch q.classify(r, p.Current) {
+		case StatusCurrent:
+			p.Propagated++
+
+		case StatusStale:
+			p.Stale++
+			if ttl > p.ETA {
+				p.ETA = ttl
+			}
+			b := sort.Search(len(ttlBuckets), func(i int) bool {
+				return ttl <= ttlBuckets[i]
+			})
+			p.Histogram[b].Servers++
+
+		case StatusDivergent:
+			p.Divergent++
+			answer, _ := q.cachedAnswer(r)
+			groups[answer].Divergent++
+		}
+	}
+
+	if total := p.Propagated + p.Stale + p.Divergent; total > 0 {
+		p.Percent = float64(p.Propagated) * 100 / float64(total)
+	}
+
+	for _, g := range groups {
+		g.Current = g.Answer == p.Current
+		p.Groups = append(p.Groups, *g)
+	}
+	sort.Slice(p.Groups, func(i, j int) bool {
+		if p.Groups[i].Servers != p.Groups[j].Servers {
+			return p.Groups[i].Servers > p.Groups[j].Servers
+		}
+		return p.Groups[i].Answer < p.Groups[j].Answer
+	})
+
+	return p
+}
+
+// Classify sets the Status of each result by comparing it to the current result, as described by Propagation.
+func (q *Query) Classify() {
+	current := q.Propagation().Current
+	for _, r := range q.Results {
+		r.Status = q.classify(r, current)
+	}
+}
+
+// classify decides whether a result is current, stale or divergent. A result that is not current is stale if it could
+// be an older copy still in the server's cache. When the authoritative servers are known, a result is divergent if it
+// is cached for longer than they give its answer, or for an answer none of them give, such as a rewritten one, for
+// longer than they give the expected result. Results that are not cached are not classified.
+func (q *Query) classify(r *Result, current string) string {
+	answer, ok := q.cachedAnswer(r)
+	if !ok {
+		return ""
+	}
+	if answer == current {
+		return StatusCurrent
+	}
+
+	ttl, ok := q.Authoritative[answer]
+	if !ok {
+		ttl = q.ExpectedTTL
+	}
+	if ttl > 0 && r.TTL > ttl {
+		return StatusDivergent
+	}
+	return StatusStale
+}
+
+// cachedAnswer gives the normalised answer or error for a result that the server will cache, and false for results
+// such as timeouts that are not cached. The answer is the alias the result followed if the query compares them.
+func (q *Query) cachedAnswer(r *Result) (string, bool) {
+	if r.Error != "" {
+		return r.Error, r.TTL > 0
+	}
+	if alias, ok := aliasAnswer(r); ok && q.CompareAlias {
+		return alias, true
+	}
+	return normaliseAnswer(r.Answer), true
+}
+
+// ToJSON prints the query, its results and the propagation estimate as JSON for use in the CLI.
+func (q *Query) ToJSON() (string, error) {
+	var class string
+	if q.Class != 0 {
+		class = q.GetClass()
+	}
+
+	text, err := json.Marshal(struct {
+		Domain        string
+		IDN           string `json:",omitempty"`
+		Type          string
+		Class         string            `json:",omitempty"`
+		Expected      string            `json:",omitempty"`
+		ExpectedTTL   uint32            `json:",omitempty"`
+		Authoritative map[string]uint32 `json:",omitempty"`
+		DNSSEC        bool              `json:",omitempty"`
+		Results       QueryResults
+		Propagation   *Propagation
+		Chains        *Chains `json:",omitempty"`
+	}{q.Domain, q.IDN, q.GetType(), class, q.Expected, q.ExpectedTTL, q.Authoritative, q.DNSSEC, q.Results,
+		q.Propagation(), q.Chains()})
+	return string(text), err
+}
+
+// propagationTextSummary produces the propagation section of Query.ToTextSummary, or an empty string if every server
+// that cached a result agrees on it.
+func (q *Query) propagationTextSummary() (text string) {
+	p := q.Propagation()
+	if p.Stale == 0 && p.Divergent == 0 {
+		return ""
+	}
+
+	text = fmt.Sprintf("\nAnd here is how far it has propagated (%.0f%%);\n\n", p.Percent)
+	if p.Propagated > 0 {
+		text += fmt.Sprintf("%d servers have the current result;\n%s\n\n", p.Propagated, p.Current)
+	} else {
+		text += fmt.Sprintf("No servers have the expected result yet;\n%s\n\n", p.Current)
+	}
+	if p.Stale > 0 {
+		text += fmt.Sprintf("%d servers are caching other results, which should all expire within %s;\n",
+			p.Stale, p.ETA)
+	}
+	if p.Divergent > 0 {
+		text += fmt.Sprintf("%d servers are divergent, caching results for longer than the authoritative servers give them;\n",
+			p.Divergent)
+	}
+
+	for _, g := range p.Groups {
+		if g.Current {
+			continue
+		}
+		text += fmt.Sprintf("%d servers for up to %s", g.Servers, g.MaxTTL)
+		if g.Divergent > 0 {
+			text += fmt.Sprintf(", %d divergent", g.Divergent)
+		}
+		text += fmt.Sprintf(";\n%s\n\n", g.Answer)
+	}
+
+	if p.Stale == 0 {
+		return text
+	}
+
+	text += "Remaining cache time of the stale results;\n"
+	for _, b := range p.Histogram {
+		label := "over " + ttlBuckets[len(ttlBuckets)-1].String()
+		if b.Max != 0 {
+			label = "up to " + b.Max.String()
+		}
+		text += fmt.Sprintf("%-16s%6d %s\n", label, b.Servers, strings.Repeat("#", (b.Servers*40+p.Stale-1)/p.Stale))
+	}
+
+	return text
+}
@@ -0,0 +1,189 @@
+package dnsyo
+
+import (
+	"encoding/json"
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestCacheTTL(t *testing.T) {
+	a1, _ := dns.NewRR("example.test. 300 IN A 192.0.2.1")
+	a2, _ := dns.NewRR("example.test. 120 IN A 192.0.2.2")
+	soa, _ := dns.NewRR("example.test. 3600 IN SOA ns.example.test. hostmaster.example.test. 1 7200 3600 86400 900")
+
+	Convey("no response is not cached", t, func() {
+		So(cacheTTL(nil), ShouldEqual, 0)
+	})
+
+	Convey("answers are cached for the lowest TTL", t, func() {
+		m := new(dns.Msg)
+		m.Answer = []dns.RR{a1, a2}
+		So(cacheTTL(m), ShouldEqual, 120)
+	})
+
+	Convey("NXDOMAIN and empty answers use the SOA negative caching time", t, func() {
+		m := new(dns.Msg)
+		m.Rcode = dns.RcodeNameError
+		m.Ns = []dns.RR{soa}
+		So(cacheTTL(m), ShouldEqual, 900)
+
+		m.Rcode = dns.RcodeSuccess
+		So(cacheTTL(m), ShouldEqual, 900)
+	})
+
+	Convey("other errors are not cached", t, func() {
+		m := new(dns.Msg)
+		m.Rcode = dns.RcodeServerFailure
+		m.Ns = []dns.RR{soa}
+		So(cacheTTL(m), ShouldEqual, 0)
+	})
+}
+
+func TestQuery_Propagation(t *testing.T) {
+	q := &Query{
+		Domain: "example.test",
+		Type:   dns.TypeA,
+		Results: QueryResults{
+			"a": &Result{Answer: "192.0.2.1\n192.0.2.2", TTL: 300},
+			"b": &Result{Answer: "192.0.2.2\n192.0.2.1", TTL: 200},
+			"c": &Result{Answer: "192.0.2.1\n192.0.2.2", TTL: 100},
+			"d": &Result{Answer: "198.51.100.1", TTL: 30},
+			"e": &Result{Answer: "198.51.100.1", TTL: 7200},
+			"f": &Result{Error: "NXDOMAIN", TTL: 600},
+			"g": &Result{Error: "TIMEOUT"},
+		},
+	}
+
+	Convey("the most common result is current by default", t, func() {
+		p := q.Propagation()
+		So(p.Current, ShouldEqual, "192.0.2.1\n192.0.2.2")
+		So(p.Groups, ShouldHaveLength, 3)
+		So(p.Groups[0], ShouldResemble, AnswerGroup{"192.0.2.1\n192.0.2.2", 3, 300 * time.Second, true, 0})
+		So(p.Groups[1], ShouldResemble, AnswerGroup{"198.51.100.1", 2, 2 * time.Hour, false, 0})
+		So(p.Groups[2], ShouldResemble, AnswerGroup{"NXDOMAIN", 1, 10 * time.Minute, false, 0})
+		So(p.Propagated, ShouldEqual, 3)
+		So(p.Stale, ShouldEqual, 3)
+		So(p.Divergent, ShouldEqual, 0)
+		So(p.Percent, ShouldEqual, 50)
+		So(p.ETA, ShouldEqual, 2*time.Hour)
+
+		So(p.Histogram, ShouldHaveLength, len(ttlBuckets)+1)
+		So(p.Histogram[0], ShouldResemble, TTLBucket{time.Minute, 1})
+		So(p.Histogram[2], ShouldResemble, TTLBucket{15 * time.Minute, 1})
+		So(p.Histogram[4], ShouldResemble, TTLBucket{6 * time.Hour, 1})
+		So(p.Histogram[len(ttlBuckets)], ShouldResemble, TTLBucket{0, 0})
+	})
+
+	Convey("the expected result overrides the most common one", t, func() {
+		q.Expected = "198.51.100.1"
+		defer func() { q.Expected = "" }()
+
+		p := q.Propagation()
+		So(p.Current, ShouldEqual, "198.51.100.1")
+		So(p.Propagated, ShouldEqual, 2)
+		So(p.Stale, ShouldEqual, 4)
+		So(p.ETA, ShouldEqual, 10*time.Minute)
+	})
+
+	Convey("results cached for longer than the authoritative servers give their answer are divergent", t, func() {
+		q.Expected, q.ExpectedTTL = "198.51.100.1", 3600
+		q.Authoritative = map[string]uint32{"198.51.100.1": 3600, "192.0.2.1\n192.0.2.2": 3600, "NXDOMAIN": 3600}
+		defer func() { q.Expected, q.ExpectedTTL, q.Authoritative = "", 0, nil }()
+
+		p := q.Propagation()
+		So(p.Propagated, ShouldEqual, 2)
+		So(p.Stale, ShouldEqual, 4)
+		So(p.Divergent, ShouldEqual, 0)
+
+		q.Authoritative["192.0.2.1\n192.0.2.2"], q.Authoritative["NXDOMAIN"] = 250, 250
+		p = q.Propagation()
+		So(p.Stale, ShouldEqual, 2)
+		So(p.Divergent, ShouldEqual, 2)
+		So(p.ETA, ShouldEqual, 200*time.Second)
+		So(p.Groups[0].Divergent, ShouldEqual, 1)
+		So(p.Percent, ShouldAlmostEqual, 100.0/3)
+
+		text := q.ToTextSummary()
+		So(text, ShouldContainSubstring, "2 servers are divergent, caching results for longer than the authoritative servers give them;\n")
+		So(text, ShouldContainSubstring, "3 servers for up to 5m0s, 1 divergent;\n")
+
+		q.Classify()
+		So(q.Results["a"].Status, ShouldEqual, StatusDivergent)
+		So(q.Results["b"].Status, ShouldEqual, StatusStale)
+		So(q.Results["d"].Status, ShouldEqual, StatusCurrent)
+		So(q.Results["f"].Status, ShouldEqual, StatusDivergent)
+		So(q.Results["g"].Status, ShouldBeEmpty)
+
+		for _, r := range q.Results {
+			r.Status = ""
+		}
+	})
+
+	Convey("when the authoritative servers agree, other answers cached for longer than the expected TTL are divergent", t, func() {
+		q.Expected, q.ExpectedTTL = "198.51.100.1", 250
+		q.Authoritative = map[string]uint32{"198.51.100.1": 250}
+		defer func() { q.Expected, q.ExpectedTTL, q.Authoritative = "", 0, nil }()
+
+		p := q.Propagation()
+		So(p.Propagated, ShouldEqual, 2)
+		So(p.Stale, ShouldEqual, 2)
+		So(p.Divergent, ShouldEqual, 2)
+		So(p.ETA, ShouldEqual, 200*time.Second)
+
+		q.Classify()
+		So(q.Results["a"].Status, ShouldEqual, StatusDivergent)
+		So(q.Results["c"].Status, ShouldEqual, StatusStale)
+		So(q.Results["e"].Status, ShouldEqual, StatusCurrent)
+		So(q.Results["f"].Status, ShouldEqual, StatusDivergent)
+
+		for _, r := range q.Results {
+			r.Status = ""
+		}
+	})
+
+	Convey("results are compared by their alias when the baseline is one", t, func() {
+		aliased := &Query{
+			Expected:     "CNAME cdn.example.net.",
+			ExpectedTTL:  300,
+			CompareAlias: true,
+			Results: QueryResults{
+				"a": &Result{Answer: "192.0.2.1", TTL: 60, Chain: []string{"example.test.", "cdn.example.net."}},
+				"b": &Result{Answer: "192.0.2.2", TTL: 60, Chain: []string{"example.test.", "cdn.example.net.", "edge.example.net."}},
+				"c": &Result{Answer: "198.51.100.1", TTL: 120},
+			},
+		}
+
+		p := aliased.Propagation()
+		So(p.Propagated, ShouldEqual, 2)
+		So(p.Stale, ShouldEqual, 1)
+		So(p.Groups[0].Answer, ShouldEqual, "CNAME cdn.example.net.")
+	})
+
+	Convey("the text summary includes the estimate", t, func() {
+		text := q.ToTextSummary()
+		So(text, ShouldContainSubstring, "\nAnd here is how far it has propagated (50%);\n\n3 servers have the current result;\n")
+		So(text, ShouldContainSubstring, "3 servers are caching other results, which should all expire within 2h0m0s;\n")
+		So(text, ShouldContainSubstring, "2 servers for up to 2h0m0s;\n198.51.100.1\n\n")
+		So(text, ShouldContainSubstring, "up to 1m0s           1 ##############\n")
+	})
+
+	Convey("nothing is shown once every server agrees", t, func() {
+		agreed := &Query{Results: QueryResults{"a": &Result{Answer: "192.0.2.1", TTL: 300}}}
+		So(agreed.propagationTextSummary(), ShouldBeEmpty)
+	})
+
+	Convey("the JSON output includes the estimate", t, func() {
+		text, err := q.ToJSON()
+		So(err, ShouldBeNil)
+
+		var out struct {
+			Type        string
+			Propagation Propagation
+		}
+		So(json.Unmarshal([]byte(text), &out), ShouldBeNil)
+		So(out.Type, ShouldEqual, "A")
+		So(out.Propagation.ETA, ShouldEqual, 2*time.Hour)
+	})
+}
@@ -3,12 +3,14 @@ package dnsyo
 import (
 	"fmt"
 	"github.com/miekg/dns"
+	"strconv"
 	"strings"
 )
 
 type resultSummary struct {
 	SuccessCount, ErrorCount int
 	Answers                  map[string]int
+	Bogus                    map[string]int
 	Errors                   map[string]int
 }
 
@@ -17,21 +19,76 @@ type Query struct {
 	Results QueryResults
 	Domain  string
 	Type    uint16
+
+	// Class is the class of the records to query, IN if it is not set
+	Class uint16
+
+	// IDN is the Unicode form of Domain if it is an internationalised name, see SetDomain
+	IDN string
+
+	// Expected is the result the servers should have once a change has propagated, with each record on a new line.
+	// The most common result is used if it is not set.
+	Expected string
+
+	// ExpectedTTL is the TTL the authoritative servers give the expected result, if known. Results with an answer the
+	// authoritative servers do not give that are cached for longer than this are divergent rather than stale.
+	ExpectedTTL uint32
+
+	// Authoritative is each answer the authoritative servers give and the longest TTL they give it, if known. Results
+	// with one of these answers cached for longer than its TTL are divergent rather than stale.
+	Authoritative map[string]uint32
+
+	// CompareAlias compares the results by the first target of their CNAME chain rather than the final records. It is
+	// set when the authoritative servers answer with an alias, as they only give the final records of targets in their
+	// own zone.
+	CompareAlias bool
+
+	// DNSSEC requests signatures and checks whether each server validates them
+	DNSSEC bool
+
+	// Validator checks the signatures on each server's answer if set
+	Validator *Validator
+
+	// ClientSubnet is the prefix sent in an EDNS0 client subnet option, see SetClientSubnet
+	ClientSubnet string
+
+	// NSID asks each server to identify the instance that answered, and Chaos additionally asks with a CHAOS TXT
+	// query if the server does not return an NSID. The CHAOS query is sent separately, so it may be answered by a
+	// different anycast instance to the one that gave the result.
+	NSID, Chaos bool
+
+	// GroupBy adds the header flags, authority or additional sections, the CNAME chain or the instance to the answers
+	// grouped in the summary, see SetGroupBy
+	GroupBy []string
 }
 
+// Parts of a response that the summary can be grouped by as well as the answer
+const (
+	GroupByFlags      = "flags"
+	GroupByAuthority  = "authority"
+	GroupByAdditional = "additional"
+	GroupByChain      = "chain"
+	GroupByInstance   = "instance"
+)
+
 // ToTextSummary prints a human readable output of the current query's results for use in the CLI.
 func (q *Query) ToTextSummary() (text string) {
 	var rs resultSummary
 	rs.Answers = make(map[string]int)
+	rs.Bogus = make(map[string]int)
 	rs.Errors = make(map[string]int)
 
 	for _, r := range q.Results {
 		if r.Error == "" && r.Answer != "" {
 			rs.SuccessCount++
-			rs.Answers[r.Answer]++
+			if r.Security == SecurityBogus {
+				rs.Bogus[q.summaryKey(r.Answer, r)]++
+			} else {
+				rs.Answers[q.summaryKey(r.Answer, r)]++
+			}
 		} else {
 			rs.ErrorCount++
-			rs.Errors[r.Error]++
+			rs.Errors[q.summaryKey(r.Error, r)]++
 		}
 	}
 
@@ -39,7 +96,7 @@ func (q *Query) ToTextSummary() (text string) {
  - RESULTS
 I asked %d servers for %s records related to %s,
 %d responded with records and %d gave errors
-Here are the results;`, len(q.Results), q.GetType(), q.Domain, rs.SuccessCount, rs.ErrorCount)
+Here are the results;`, len(q.Results), q.describeType(), q.DisplayDomain(), rs.SuccessCount, rs.ErrorCount)
 	text += "\n\n\n"
 
 	if rs.SuccessCount > 0 {
@@ -48,6 +105,14 @@ Here are the results;`, len(q.Results), q.GetType(), q.Domain, rs.SuccessCount,
 		}
 	}
 
+	if len(rs.Bogus) > 0 {
+		text += fmt.Sprint("\nAnd here are the answers that failed DNSSEC validation;\n\n")
+
+		for result, count := range rs.Bogus {
+			text += fmt.Sprintf("%d servers responded with;\n%s\n\n", count, result)
+		}
+	}
+
 	if rs.ErrorCount > 0 {
 		text += fmt.Sprint("\nAnd here are the errors;\n\n")
 
@@ -56,14 +121,161 @@ Here are the results;`, len(q.Results), q.GetType(), q.Domain, rs.SuccessCount,
 		}
 	}
 
+	text += q.svcbTextSummary()
+	text += q.chainTextSummary()
+	text += q.propagationTextSummary()
+	text += q.Results.dnssecTextSummary()
+	text += q.Results.securityTextSummary()
+	text += q.Results.latencyTextSummary()
+
+	return text
+}
+
+// SetGroupBy checks the parts of the response to group the summary by are known and sets them on the query.
+// Grouping by instance also asks each server for its NSID.
+func (q *Query) SetGroupBy(parts []string) error {
+	for i, part := range parts {
+		part = strings.ToLower(part)
+		switch part {
+		case GroupByInstance:
+			q.NSID = true
+			parts[i] = part
+		case GroupByFlags, GroupByAuthority, GroupByAdditional, GroupByChain:
+			parts[i] = part
+		default:
+			return fmt.Errorf("unable to group by %s", part)
+		}
+	}
+	q.GroupBy = parts
+	return nil
+}
+
+// summaryKey adds the parts of the result the summary is grouped by to its answer or error. The TTLs are left out of
+// the records so that servers are not split up by how long they have cached them for.
+func (q *Query) summaryKey(text string, r *Result) string {
+	for _, part := range q.GroupBy {
+		switch part {
+		case GroupByFlags:
+			text += "\nflags: " + r.Flags
+		case GroupByAuthority:
+			text += "\nauthority:" + withoutTTLs(r.Authority)
+		case GroupByAdditional:
+			text += "\nadditional:" + withoutTTLs(r.Additional)
+		case GroupByChain:
+			if chain := q.chainText(r); chain != "" {
+				text += "\nchain: " + chain
+			} else {
+				text += "\nchain: none"
+			}
+		case GroupByInstance:
+			if r.Instance != "" {
+				text += "\ninstance: " + r.Instance
+			} else {
+				text += "\ninstance: unknown"
+			}
+		}
+	}
 	return text
 }
 
+// withoutTTLs formats records for summaryKey, indented on their own lines without their TTLs.
+func withoutTTLs(records []string) (text string) {
+	if len(records) == 0 {
+		return " none"
+	}
+
+	for _, rr := range records {
+		fields := strings.Split(rr, "\t")
+		if len(fields) > 1 {
+			fields = append(fields[:1], fields[2:]...)
+		}
+		text += "\n  " + strings.Join(fields, " ")
+	}
+	return
+}
+
+// message creates the question sent to each server for the query.
+func (q *Query) message() (msg *dns.Msg) {
+	if q.DNSSEC || q.Validator != nil {
+		msg = dnssecQuestion(q.Domain, q.Type, false)
+	} else {
+		msg = newQuestion(q.Domain, q.Type, true)
+	}
+
+	if q.Class != 0 {
+		msg.Question[0].Qclass = q.Class
+	}
+
+	if q.ClientSubnet != "" {
+		// the subnet is checked by SetClientSubnet
+		if subnet, err := parseClientSubnet(q.ClientSubnet); err == nil {
+			opt := edns0(msg)
+			opt.Option = append(opt.Option, subnet)
+		}
+	}
+
+	if q.NSID || q.Chaos {
+		requestNSID(msg)
+	}
+
+	return msg
+}
+
+// result sends the query to a server and creates its result, with the details the query's options ask for.
+// The probes are shared by every query in the run, so that each server is only checked for validation once.
+func (q *Query) result(s Server, probes *validationProbes) *Result {
+	resp, rtt, err := s.send(q.message())
+	r := newResult(s, resp, rtt, err)
+	q.inspect(s, r, resp, probes)
+	return r
+}
+
+// inspect adds the details of the response the query's options ask for to a server's result.
+func (q *Query) inspect(s Server, r *Result, resp *dns.Msg, probes *validationProbes) {
+	if q.DNSSEC {
+		if resp != nil {
+			r.AD, r.RRSIG = resp.AuthenticatedData, hasSignatures(resp)
+		}
+		r.Validation = probes.check(s)
+	}
+
+	if q.Validator != nil && resp != nil {
+		r.Security = q.Validator.Validate(resp)
+	}
+
+	if q.ClientSubnet != "" && resp != nil {
+		r.ECSScope = responseScope(resp)
+	}
+
+	if (q.NSID || q.Chaos) && resp != nil {
+		r.Instance = responseNSID(resp)
+	}
+	if q.Chaos && r.Instance == "" {
+		r.Instance = s.chaosIdentity()
+	}
+}
+
+// edns0 returns the OPT record of a message, adding one if it does not have one.
+func edns0(msg *dns.Msg) *dns.OPT {
+	if opt := msg.IsEdns0(); opt != nil {
+		return opt
+	}
+	msg.SetEdns0(4096, false)
+	return msg.IsEdns0()
+}
+
 // SetType converts a string representation of a query type to the internal uint16. This is then set on the current Query.
-// An error is returned if the type cannot be found in the miekg/dns library.
+// Types unknown to the miekg/dns library can be given by number in the RFC 3597 form, such as TYPE65534, otherwise an
+// error is returned if the type cannot be found.
 func (q *Query) SetType(recordType string) error {
 	recordType = strings.ToUpper(recordType)
 	t, ok := dns.StringToType[recordType]
+	if !ok {
+		t, ok = extraTypes[recordType]
+	}
+	if !ok {
+		t, ok = parseNumbered(recordType, "TYPE")
+	}
 	if !ok {
 		return fmt.Errorf("unable to use record type %s", recordType)
 	}
@@ -71,10 +283,70 @@ func (q *Query) SetType(recordType string) error {
 	return nil
 }
 
+// SetClass converts a string representation of a class such as CH to the internal uint16 and sets it on the query.
+// Classes can also be given by number in the RFC 3597 form, such as CLASS255.
+func (q *Query) SetClass(class string) error {
+	class = strings.ToUpper(class)
+	c, ok := dns.StringToClass[class]
+	if !ok {
+		c, ok = parseNumbered(class, "CLASS")
+	}
+	if !ok {
+		return fmt.Errorf("unable to use class %s", class)
+	}
+	q.Class = c
+	return nil
+}
+
+// parseNumbered parses the RFC 3597 form of a type or class, the prefix followed by its number. Zero is rejected as
+// it is reserved, and would be taken as the type or class not being set.
+func parseNumbered(s, prefix string) (uint16, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[len(prefix):], 10, 16)
+	if err != nil || n == 0 {
+		return 0, false
+	}
+	return uint16(n), true
+}
+
+// SetReverse sets the query to look up the PTR record of an IPv4 or IPv6 address, using its in-addr.arpa or ip6.arpa
+// name as the domain.
+func (q *Query) SetReverse(ip string) error {
+	name, err := dns.ReverseAddr(ip)
+	if err != nil {
+		return fmt.Errorf("unable to reverse %s, it is not an IP address", ip)
+	}
+	q.Domain, q.IDN = name, ""
+	return q.SetType("PTR")
+}
+
 // GetType looks up the current Query's uint16 Type and returns the string representation of it from the miekg/dns library.
 func (q *Query) GetType() string {
-	if q.Type != 0 {
-		return dns.TypeToString[q.Type]
+	if q.Type == 0 {
+		return ""
+	}
+	for name, t := range extraTypes {
+		if t == q.Type {
+			return name
+		}
+	}
+	return dns.Type(q.Type).String()
+}
+
+// GetClass returns the string representation of the current Query's class, which is IN if it is not set.
+func (q *Query) GetClass() string {
+	if q.Class != 0 {
+		return dns.Class(q.Class).String()
+	}
+	return dns.ClassToString[dns.ClassINET]
+}
+
+// describeType is the type of records queried for summaries, including the class if it is not IN.
+func (q *Query) describeType() string {
+	if q.Class != 0 && q.Class != dns.ClassINET {
+		return q.GetClass() + " " + q.GetType()
 	}
-	return ""
+	return q.GetType()
 }
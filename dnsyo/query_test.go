@@ -1,6 +1,7 @@
 package dnsyo
 
 import (
+	"fmt"
 	"github.com/miekg/dns"
 	. "github.com/smartystreets/goconvey/convey"
 	"testing"
@@ -94,10 +95,42 @@ func TestQuery_SetType(t *testing.T) {
 		})
 	})
 
+	Convey("types can be given by number", t, func() {
+		So(q.SetType("type65534"), ShouldBeNil)
+		So(q.Type, ShouldEqual, 65534)
+		So(q.GetType(), ShouldEqual, "TYPE65534")
+
+		So(q.SetType("TYPE1"), ShouldBeNil)
+		So(q.GetType(), ShouldEqual, "A")
+	})
+
 	Convey("setting an invalid type throws an error", t, func() {
 		err := q.SetType("FOO")
 		So(err, ShouldBeError)
 		So(err.Error(), ShouldContainSubstring, "FOO")
+
+		So(q.SetType("TYPE70000"), ShouldBeError)
+		So(q.SetType("TYPE0"), ShouldBeError)
+	})
+}
+
+func TestQuery_SetClass(t *testing.T) {
+	Convey("classes are set by name or number", t, func() {
+		q := &Query{Type: dns.TypeTXT}
+		So(q.GetClass(), ShouldEqual, "IN")
+
+		So(q.SetClass("ch"), ShouldBeNil)
+		So(q.Class, ShouldEqual, dns.ClassCHAOS)
+		So(q.describeType(), ShouldEqual, "CH TXT")
+		So(q.message().Question[0].Qclass, ShouldEqual, dns.ClassCHAOS)
+
+		So(q.SetClass("CLASS42"), ShouldBeNil)
+		So(q.GetClass(), ShouldEqual, "CLASS42")
+	})
+
+	Convey("unknown classes are an error", t, func() {
+		So((&Query{}).SetClass("XX"), ShouldBeError, "unable to use class XX")
+		So((&Query{}).SetClass("CLASS0"), ShouldBeError, "unable to use class CLASS0")
 	})
 }
 
@@ -116,3 +149,52 @@ func TestQuery_GetType(t *testing.T) {
 		So(t, ShouldEqual, "A")
 	})
 }
+
+func TestQuery_GroupBy(t *testing.T) {
+	soa := "example.test.\t%d\tIN\tSOA\tns.example.test. hostmaster.example.test. 1 2 3 4 5"
+	q := &Query{
+		Domain: "missing.example.test",
+		Type:   dns.TypeA,
+		Results: QueryResults{
+			"a": &Result{Error: "NOANSWER", Flags: "qr rd ra", Authority: []string{fmt.Sprintf(soa, 300)}},
+			"b": &Result{Error: "NOANSWER", Flags: "qr rd ra", Authority: []string{fmt.Sprintf(soa, 120)}},
+			"c": &Result{Error: "NOANSWER", Flags: "qr aa rd"},
+		},
+	}
+
+	Convey("unknown parts are rejected", t, func() {
+		So(q.SetGroupBy([]string{"answer"}), ShouldBeError)
+	})
+
+	Convey("results are grouped by the authority section without TTLs", t, func() {
+		So(q.SetGroupBy([]string{"Authority"}), ShouldBeNil)
+		text := q.ToTextSummary()
+		So(text, ShouldContainSubstring, "2 servers responded with;\nNOANSWER\nauthority:\n"+
+			"  example.test. IN SOA ns.example.test. hostmaster.example.test. 1 2 3 4 5\n\n")
+		So(text, ShouldContainSubstring, "1 servers responded with;\nNOANSWER\nauthority: none\n\n")
+	})
+
+	Convey("results are grouped by flags", t, func() {
+		So(q.SetGroupBy([]string{"flags"}), ShouldBeNil)
+		text := q.ToTextSummary()
+		So(text, ShouldContainSubstring, "2 servers responded with;\nNOANSWER\nflags: qr rd ra\n\n")
+		So(text, ShouldContainSubstring, "1 servers responded with;\nNOANSWER\nflags: qr aa rd\n\n")
+	})
+}
+
+func TestQuery_SetReverse(t *testing.T) {
+	Convey("IPv4 and IPv6 addresses are looked up by their arpa name", t, func() {
+		q := &Query{}
+		So(q.SetReverse("203.0.113.7"), ShouldBeNil)
+		So(q.Domain, ShouldEqual, "7.113.0.203.in-addr.arpa.")
+		So(q.Type, ShouldEqual, dns.TypePTR)
+
+		So(q.SetReverse("2001:db8::1"), ShouldBeNil)
+		So(q.Domain, ShouldEqual, "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.")
+	})
+
+	Convey("names cannot be reversed", t, func() {
+		q := &Query{}
+		So(q.SetReverse("example.com"), ShouldBeError, "unable to reverse example.com, it is not an IP address")
+	})
+}
@@ -0,0 +1,135 @@
+package dnsyo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// UpdateReport describes the outcome of testing a list of servers, compared against a previous list of servers
+// so that changes can be reviewed before the new list is saved.
+type UpdateReport struct {
+	Tested   int
+	Added    ServerList
+	Removed  ServerList
+	Retained ServerList
+	Failures map[string]ServerList
+}
+
+// NewUpdateReport compares the working servers from a test run against the previous list, matching servers by IP.
+// Failures should be grouped by reason as returned by ServerList.TestAllWithFailures.
+// The previous list may be empty if there is nothing to compare against.
+func NewUpdateReport(previous, working ServerList, failures map[string]ServerList) *UpdateReport {
+	r := &UpdateReport{
+		Failures: failures,
+	}
+	if r.Failures == nil {
+		r.Failures = make(map[string]ServerList)
+	}
+
+	r.Tested = len(working)
+	for _, fl := range r.Failures {
+		r.Tested += len(fl)
+	}
+
+	old := make(map[string]bool)
+	for _, s := range previous {
+		old[s.IP] = true
+	}
+
+	current := make(map[string]bool)
+	for _, s := range working {
+		current[s.IP] = true
+		if old[s.IP] {
+			r.Retained = append(r.Retained, s)
+		} else {
+			r.Added = append(r.Added, s)
+		}
+	}
+
+	for _, s := range previous {
+		if !current[s.IP] {
+			r.Removed = append(r.Removed, s)
+		}
+	}
+
+	r.Added.sortByIP()
+	r.Removed.sortByIP()
+	r.Retained.sortByIP()
+	for _, fl := range r.Failures {
+		fl.sortByIP()
+	}
+
+	return r
+}
+
+// failureReason finds the reason a server was disabled, if it was tested at all.
+func (r *UpdateReport) failureReason(s Server) string {
+	for reason, fl := range r.Failures {
+		for _, f := range fl {
+			if f.IP == s.IP {
+				return reason
+			}
+		}
+	}
+	return "NOT TESTED"
+}
+
+// ToTextSummary prints a human readable output of the report for use in the CLI.
+func (r *UpdateReport) ToTextSummary() (text string) {
+	working := len(r.Added) + len(r.Retained)
+
+	text = fmt.Sprintf(`
+ - UPDATE REPORT
+I tested %d servers,
+%d are working and %d were disabled
+%d were added, %d were removed and %d are still working`,
+		r.Tested, working, r.Tested-working, len(r.Added), len(r.Removed), len(r.Retained))
+	text += "\n\n\n"
+
+	if len(r.Added) > 0 {
+		text += fmt.Sprintf("%d servers were added;\n", len(r.Added))
+		for _, s := range r.Added {
+			text += fmt.Sprintf("%s\t%s\t%s\n", s.IP, s.Country, s.Name)
+		}
+		text += "\n"
+	}
+
+	if len(r.Removed) > 0 {
+		text += fmt.Sprintf("%d servers were removed;\n", len(r.Removed))
+		for _, s := range r.Removed {
+			text += fmt.Sprintf("%s\t%s\t%s\t%s\n", s.IP, s.Country, s.Name, r.failureReason(s))
+		}
+		text += "\n"
+	}
+
+	if len(r.Failures) > 0 {
+		text += fmt.Sprint("\nAnd here are the failures;\n\n")
+
+		reasons := make([]string, 0, len(r.Failures))
+		for reason := range r.Failures {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+
+		for _, reason := range reasons {
+			text += fmt.Sprintf("%d servers failed with;\n%s\n\n", len(r.Failures[reason]), reason)
+		}
+	}
+
+	return text
+}
+
+// ToJSON prints a verbose JSON representation of the report
+func (r *UpdateReport) ToJSON() (string, error) {
+	text, err := json.Marshal(r)
+	return string(text), err
+}
+
+// sortByIP orders the list in place so that output is stable between runs.
+func (sl *ServerList) sortByIP() {
+	l := *sl
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].IP < l[j].IP
+	})
+}
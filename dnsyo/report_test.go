@@ -0,0 +1,55 @@
+package dnsyo
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestNewUpdateReport(t *testing.T) {
+	kept := Server{IP: "127.0.0.1", Country: "GB", Name: "kept"}
+	added := Server{IP: "127.0.0.2", Country: "US", Name: "added"}
+	removed := Server{IP: "127.0.0.3", Country: "DE", Name: "removed"}
+	gone := Server{IP: "127.0.0.4", Country: "FR", Name: "gone"}
+
+	previous := ServerList{kept, removed, gone}
+	working := ServerList{added, kept}
+	failures := map[string]ServerList{
+		"TIMEOUT": {removed},
+	}
+
+	r := NewUpdateReport(previous, working, failures)
+
+	Convey("servers are sorted into the correct groups", t, func() {
+		So(r.Tested, ShouldEqual, 3)
+		So(r.Added, ShouldResemble, ServerList{added})
+		So(r.Retained, ShouldResemble, ServerList{kept})
+		So(r.Removed, ShouldResemble, ServerList{removed, gone})
+	})
+
+	Convey("with no previous list everything is added", t, func() {
+		r := NewUpdateReport(nil, working, nil)
+		So(r.Added, ShouldResemble, ServerList{kept, added})
+		So(r.Removed, ShouldBeEmpty)
+		So(r.Failures, ShouldNotBeNil)
+	})
+
+	Convey("text summary", t, func() {
+		text := r.ToTextSummary()
+		So(text, ShouldStartWith, `
+ - UPDATE REPORT
+I tested 3 servers,
+2 are working and 1 were disabled
+1 were added, 2 were removed and 1 are still working`)
+		So(text, ShouldContainSubstring, "1 servers were added;\n127.0.0.2\tUS\tadded\n\n")
+		So(text, ShouldContainSubstring, "127.0.0.3\tDE\tremoved\tTIMEOUT\n")
+		So(text, ShouldContainSubstring, "127.0.0.4\tFR\tgone\tNOT TESTED\n")
+		So(text, ShouldContainSubstring, "1 servers failed with;\nTIMEOUT\n\n")
+	})
+
+	Convey("json", t, func() {
+		json, err := r.ToJSON()
+		So(err, ShouldBeNil)
+		So(json, ShouldContainSubstring, `"Tested":3`)
+		So(json, ShouldContainSubstring, `"Failures":{"TIMEOUT":[{"IP":"127.0.0.3","Country":"DE","Name":"removed"}]}`)
+	})
+}
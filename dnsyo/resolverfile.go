@@ -0,0 +1,201 @@
+package dnsyo
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// ResolverFileVersion is the version of the resolver file schema written by DumpToFile.
+	// Files without a version are the original list of servers and are read as version 0.
+	ResolverFileVersion = 1
+
+	resolverFileHeader = "#### GENERATED BY dnsyo update ####\n\n"
+)
+
+// resolverFile is the schema of the YAML resolver file
+type resolverFile struct {
+	Version int
+	Servers ServerList
+}
+
+// ValidationProblem is a single issue found in a resolver file, with the line of the server entry it refers to.
+type ValidationProblem struct {
+	Line    int
+	Message string
+}
+
+// ValidationError is returned when loading a resolver file that contains invalid entries.
+type ValidationError struct {
+	Filename string
+	Problems []ValidationProblem
+}
+
+func (e *ValidationError) Error() string {
+	lines := []string{"invalid server list:"}
+	if e.Filename != "" {
+		lines[0] = fmt.Sprintf("invalid resolver file %s:", e.Filename)
+	}
+	for _, p := range e.Problems {
+		if p.Line > 0 {
+			lines = append(lines, fmt.Sprintf("  line %d: %s", p.Line, p.Message))
+		} else {
+			lines = append(lines, "  "+p.Message)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseResolverFile reads the servers from the contents of a resolver file, migrating from older versions as required.
+func parseResolverFile(data []byte) (sl ServerList, version int, err error) {
+	var doc interface{}
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, 0, err
+	}
+
+	switch doc.(type) {
+	case nil:
+		return nil, ResolverFileVersion, nil
+
+	case []interface{}:
+		// version 0 files are a bare list of servers
+		err = yaml.Unmarshal(data, &sl)
+		return sl, 0, err
+	}
+
+	var rf resolverFile
+	if err = yaml.Unmarshal(data, &rf); err != nil {
+		return nil, 0, err
+	}
+	if rf.Version < 1 || rf.Version > ResolverFileVersion {
+		return nil, rf.Version, fmt.Errorf("unsupported resolver file version %d", rf.Version)
+	}
+
+	return rf.Servers, rf.Version, nil
+}
+
+// marshalResolverFile produces the contents of a resolver file in the current version for the given servers.
+func marshalResolverFile(sl ServerList) ([]byte, error) {
+	yml, err := yaml.Marshal(resolverFile{
+		Version: ResolverFileVersion,
+		Servers: sl,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(resolverFileHeader), yml...), nil
+}
+
+// Validate checks that every server in the list has a valid IP address and country code, and that no IP address is
+// listed more than once. All of the problems found are returned in a ValidationError.
+func (sl *ServerList) Validate() error {
+	return sl.validate("", nil)
+}
+
+// validate performs Validate, using the original file contents, if available, to find the line of each problem.
+func (sl *ServerList) validate(filename string, data []byte) error {
+	lines := itemLines(data)
+	lineOf := func(i int) int {
+		if i < len(lines) {
+			return lines[i]
+		}
+		return 0
+	}
+
+	var problems []ValidationProblem
+	seen := make(map[string]int)
+	for i, s := range *sl {
+		if net.ParseIP(s.IP) == nil {
+			problems = append(problems, ValidationProblem{lineOf(i), fmt.Sprintf("invalid IP address %q", s.IP)})
+		}
+
+		if _, ok := countryContinents[s.Country]; !ok && s.Country != "" {
+			problems = append(problems, ValidationProblem{lineOf(i), fmt.Sprintf("invalid country code %q for %s", s.Country, s.IP)})
+		}
+
+		if first, ok := seen[s.IP]; ok {
+			msg := fmt.Sprintf("duplicate server %s", s.IP)
+			if l := lineOf(first); l > 0 {
+				msg += fmt.Sprintf(", first listed on line %d", l)
+			}
+			problems = append(problems, ValidationProblem{lineOf(i), msg})
+		} else {
+			seen[s.IP] = i
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{
+			Filename: filename,
+			Problems: problems,
+		}
+	}
+	return nil
+}
+
+var listItem = regexp.MustCompile(`^(\s*)- `)
+
+// itemLines finds the line numbers of each item in the list of servers in the contents of a resolver file, assuming
+// that the servers are the first list in the file. Nested lists are ignored as they are indented further.
+func itemLines(data []byte) (lines []int) {
+	indent := -1
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		m := listItem.FindSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		if indent < 0 {
+			indent = len(m[1])
+		}
+		if len(m[1]) == indent {
+			lines = append(lines, i+1)
+		}
+	}
+	return
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as filename and renames it into place, so
+// that readers never see a partially written file.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	return writeStreamAtomic(filename, bytes.NewReader(data), perm)
+}
+
+// writeStreamAtomic performs writeFileAtomic, copying the data from a reader.
+func writeStreamAtomic(filename string, r io.Reader, perm os.FileMode) (err error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	if err = os.Chmod(tmp.Name(), perm); err != nil {
+		return
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,108 @@
+package dnsyo
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const (
+	tmpResolverFile = ".resolverfile-test.yml"
+)
+
+func TestParseResolverFile(t *testing.T) {
+	googleA := Server{
+		IP:      "8.8.8.8",
+		Country: "US",
+		Name:    "google-public-dns-a.google.com",
+	}
+
+	Convey("the original headerless format is migrated", t, func() {
+		sl, version, err := parseResolverFile([]byte(`#### GENERATED BY dnsyo update ####
+
+- ip: 8.8.8.8
+  country: US
+  name: google-public-dns-a.google.com
+`))
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, 0)
+		So(sl, ShouldResemble, ServerList{googleA})
+	})
+
+	Convey("the current version is read", t, func() {
+		data, err := marshalResolverFile(ServerList{googleA})
+		So(err, ShouldBeNil)
+		So(string(data), ShouldStartWith, resolverFileHeader+"version: 1\n")
+
+		sl, version, err := parseResolverFile(data)
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, ResolverFileVersion)
+		So(sl, ShouldResemble, ServerList{googleA})
+	})
+
+	Convey("newer versions are rejected", t, func() {
+		_, _, err := parseResolverFile([]byte("version: 99\nservers: []\n"))
+		So(err, ShouldBeError)
+		So(err.Error(), ShouldContainSubstring, "99")
+	})
+}
+
+func TestServerList_Validate(t *testing.T) {
+	Convey("a valid list has no problems", t, func() {
+		sl, err := ServersFromFile(testYaml)
+		So(err, ShouldBeNil)
+		So(sl.Validate(), ShouldBeNil)
+	})
+
+	Convey("every problem is reported with its line", t, func() {
+		data := []byte(`version: 1
+servers:
+- ip: 8.8.8.8
+  country: US
+- ip: 8.8.8.256
+  country: US
+- ip: 8.8.4.4
+  country: ZZ
+- ip: 8.8.8.8
+  country: US
+`)
+		err := ioutil.WriteFile(tmpResolverFile, data, 0644)
+		So(err, ShouldBeNil)
+		defer os.Remove(tmpResolverFile)
+
+		_, err = ServersFromFile(tmpResolverFile)
+		So(err, ShouldHaveSameTypeAs, &ValidationError{})
+
+		verr := err.(*ValidationError)
+		So(verr.Problems, ShouldResemble, []ValidationProblem{
+			{5, `invalid IP address "8.8.8.256"`},
+			{7, `invalid country code "ZZ" for 8.8.4.4`},
+			{9, "duplicate server 8.8.8.8, first listed on line 3"},
+		})
+		So(err.Error(), ShouldStartWith, "invalid resolver file "+tmpResolverFile+":\n  line 5: ")
+	})
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	Convey("the file is written with the given permissions", t, func() {
+		err := writeFileAtomic(tmpResolverFile, []byte("test"), 0644)
+		So(err, ShouldBeNil)
+		defer os.Remove(tmpResolverFile)
+
+		info, err := os.Stat(tmpResolverFile)
+		So(err, ShouldBeNil)
+		So(info.Mode().Perm(), ShouldEqual, os.FileMode(0644))
+
+		data, _ := ioutil.ReadFile(tmpResolverFile)
+		So(string(data), ShouldEqual, "test")
+
+		Convey("and replaced by the next write", func() {
+			err := writeFileAtomic(tmpResolverFile, []byte("again"), 0644)
+			So(err, ShouldBeNil)
+
+			data, _ := ioutil.ReadFile(tmpResolverFile)
+			So(string(data), ShouldEqual, "again")
+		})
+	})
+}
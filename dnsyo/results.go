@@ -1,11 +1,124 @@
 package dnsyo
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"github.com/miekg/dns"
+	"strings"
+	"time"
+)
 
 // Result contains an answer or error from a single server
 type Result struct {
 	Answer string
 	Error  string `json:",omitempty"`
+
+	// ErrorCode is the stable code of the error for machine use, see LookupError, and Rcode is the response code of
+	// rcode errors
+	ErrorCode string `json:",omitempty"`
+	Rcode     int    `json:",omitempty"`
+
+	// RTT is the time taken for the server to respond, or 0 if it did not respond
+	RTT time.Duration `json:",omitempty"`
+
+	// Country is the country of the server that gave the result
+	Country string `json:",omitempty"`
+
+	// TTL is the number of seconds the server will keep the result cached for. For NXDOMAIN and NOANSWER results this
+	// is the negative caching time from the SOA record, other errors are not cached and have no TTL.
+	TTL uint32 `json:",omitempty"`
+
+	// Status is set by Query.Classify to show whether the result is current, stale or divergent
+	Status string `json:",omitempty"`
+
+	// AD and RRSIG are set in DNSSEC mode if the server set the authenticated data flag and returned signatures
+	AD    bool `json:",omitempty"`
+	RRSIG bool `json:",omitempty"`
+
+	// Validation is whether the server validates DNSSEC signatures, only checked in DNSSEC mode
+	Validation string `json:",omitempty"`
+
+	// Security is whether the answer is secure, insecure or bogus when validated by the query's Validator
+	Security string `json:",omitempty"`
+
+	// ECSScope is the prefix length the answer applies to when a client subnet was sent, if the server returned one
+	ECSScope *uint8 `json:",omitempty"`
+
+	// Instance identifies the machine behind the server that answered, from its NSID or CHAOS TXT identity
+	Instance string `json:",omitempty"`
+
+	// Flags are the header flags of the response in the same format as dig, such as "qr rd ra"
+	Flags string `json:",omitempty"`
+
+	// Authority and Additional are the records in the other sections of the response, in zone file format
+	Authority  []string `json:",omitempty"`
+	Additional []string `json:",omitempty"`
+
+	// Chain is the name queried followed by each CNAME target the server followed to reach the answer, if it was an
+	// alias. The Answer is then only the records for the last name in the chain.
+	Chain []string `json:",omitempty"`
+
+	// SVCB holds the parsed SVCB or HTTPS records of the answer
+	SVCB []SVCBRecord `json:",omitempty"`
+}
+
+// newResult creates the result for a server from its response, or the error if the server failed to give an answer.
+func newResult(s Server, resp *dns.Msg, rtt time.Duration, err error) *Result {
+	r := &Result{
+		RTT:     rtt,
+		Country: s.Country,
+		TTL:     cacheTTL(resp),
+	}
+
+	// the chain is recorded for errors too, as an alias to a name that does not resolve is a common cause of them
+	var final []dns.RR
+	if resp != nil {
+		r.Flags = headerFlags(resp)
+		r.Authority = records(resp.Ns)
+		r.Additional = records(resp.Extra)
+		r.Chain, final = cnameChain(resp)
+	}
+
+	if err != nil {
+		r.Error = err.Error()
+		if le, ok := err.(*LookupError); ok {
+			r.ErrorCode, r.Rcode = le.Code, le.Rcode
+		}
+		return r
+	}
+
+	answer := resp.Answer
+	if len(final) > 0 {
+		answer = final
+	}
+
+	var res []string
+	for _, rr := range answer {
+		// signatures are only part of the answer if they were asked for, otherwise they are reported by RRSIG
+		if rr.Header().Rrtype == dns.TypeRRSIG && (len(resp.Question) == 0 || resp.Question[0].Qtype != dns.TypeRRSIG) {
+			continue
+		}
+		if isSVCB(rr.Header().Rrtype) {
+			if s, err := parseSVCB(rr); err == nil {
+				r.SVCB = append(r.SVCB, *s)
+				res = append(res, s.String())
+				continue
+			}
+		}
+		res = append(res, strings.Split(rr.String(), "\t")[answerResult])
+	}
+	r.Answer = strings.Join(res, "\n")
+	return r
+}
+
+// records formats the records in a section of a response, leaving out the OPT pseudo record.
+func records(section []dns.RR) (text []string) {
+	for _, rr := range section {
+		if rr.Header().Rrtype == dns.TypeOPT {
+			continue
+		}
+		text = append(text, rr.String())
+	}
+	return
 }
 
 // QueryResults maps servers by name to the results they provide so a more detailed response can be given.
@@ -1,6 +1,7 @@
 package dnsyo
 
 import (
+	"github.com/miekg/dns"
 	. "github.com/smartystreets/goconvey/convey"
 	"testing"
 )
@@ -17,3 +18,47 @@ func TestQueryResults_ToJson(t *testing.T) {
 		So(json, ShouldEqual, `{"error":{"Answer":"","Error":"TESTERR"},"localhost":{"Answer":"127.0.0.1"}}`)
 	})
 }
+
+func TestNewResult(t *testing.T) {
+	soa, _ := dns.NewRR("example.test. 900 IN SOA ns.example.test. hostmaster.example.test. 1 2 3 4 5")
+	glue, _ := dns.NewRR("ns.example.test. 3600 IN A 192.0.2.53")
+
+	resp := new(dns.Msg)
+	resp.SetQuestion("missing.example.test.", dns.TypeA)
+	resp.Response, resp.RecursionAvailable = true, true
+	resp.Ns = []dns.RR{soa}
+	resp.Extra = []dns.RR{glue}
+	resp.SetEdns0(4096, false)
+
+	Convey("the flags and other sections are recorded for errors", t, func() {
+		r := newResult(Server{Country: "GB"}, resp, 0, ErrNoAnswer)
+		So(r.Error, ShouldEqual, "NOANSWER")
+		So(r.ErrorCode, ShouldEqual, CodeNoAnswer)
+		So(r.Flags, ShouldEqual, "qr rd ra")
+		So(r.Authority, ShouldResemble, []string{soa.String()})
+		So(r.Additional, ShouldResemble, []string{glue.String()})
+		So(r.TTL, ShouldEqual, 5)
+	})
+
+	Convey("nothing is recorded if the server did not respond", t, func() {
+		r := newResult(Server{}, nil, 0, ErrTimeout)
+		So(r, ShouldResemble, &Result{Error: "TIMEOUT", ErrorCode: CodeTimeout})
+	})
+
+	Convey("error responses record the response code", t, func() {
+		r := newResult(Server{}, nil, 0, RcodeError(dns.RcodeNameError))
+		So(r, ShouldResemble, &Result{Error: "NXDOMAIN", ErrorCode: CodeRcode, Rcode: dns.RcodeNameError})
+	})
+}
+
+func TestNewResult_Unknown(t *testing.T) {
+	Convey("records of unknown types are shown in the RFC 3597 form", t, func() {
+		rr, err := dns.NewRR(`example.test. 300 IN TYPE65534 \# 4 0a000001`)
+		So(err, ShouldBeNil)
+
+		resp := new(dns.Msg)
+		resp.SetQuestion("example.test.", 65534)
+		resp.Answer = []dns.RR{rr}
+		So(newResult(Server{}, resp, 0, nil).Answer, ShouldEqual, `\# 4 0a000001`)
+	})
+}
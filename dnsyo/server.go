@@ -5,7 +5,7 @@ import (
 	"github.com/miekg/dns"
 	"net"
 	"strings"
-	"syscall"
+	"time"
 )
 
 // Server contains information about a specific nameserver that can be queried
@@ -13,6 +13,28 @@ type Server struct {
 	IP      string
 	Country string
 	Name    string
+
+	// Software is the name and version of the dns daemon the server is running, if known
+	Software string `yaml:"software,omitempty" json:",omitempty"`
+
+	// DNSSEC indicates the server supports DNSSEC
+	DNSSEC bool `yaml:"dnssec,omitempty" json:",omitempty"`
+
+	// Pinned servers are always included when selecting a random sample of servers
+	Pinned bool `yaml:"pinned,omitempty" json:",omitempty"`
+
+	// Disabled servers are kept in the list but never queried
+	Disabled bool `yaml:"disabled,omitempty" json:",omitempty"`
+
+	// Tags are free form labels used to curate the list
+	Tags []string `yaml:"tags,omitempty" json:",omitempty"`
+}
+
+// testQuestions are the queries used by Test, for domains that should be widely available.
+var testQuestions = []dns.Question{
+	{Name: dns.Fqdn("google.com"), Qtype: dns.TypeA, Qclass: dns.ClassINET},
+	{Name: dns.Fqdn("facebook.com"), Qtype: dns.TypeA, Qclass: dns.ClassINET},
+	{Name: dns.Fqdn("amazon.com"), Qtype: dns.TypeA, Qclass: dns.ClassINET},
 }
 
 // Test checks that the server can be reached and is returning results for three common domains that should be
@@ -20,31 +42,35 @@ type Server struct {
 //
 // It does not verify that the results returned are correct and not being squatted.
 func (s *Server) Test() (ok bool, err error) {
-	tests := []dns.Question{
-		{dns.Fqdn("google.com"), dns.TypeA, dns.ClassINET},
-		{dns.Fqdn("facebook.com"), dns.TypeA, dns.ClassINET},
-		{dns.Fqdn("amazon.com"), dns.TypeA, dns.ClassINET},
-	}
+	_, err = s.test()
+	return err == nil, err
+}
 
-	addr := s.IP + ":53"
+// test performs the checks for Test, additionally returning the average round trip time of the successful queries.
+func (s *Server) test() (rtt time.Duration, err error) {
+	addr := s.addr()
 	c := new(dns.Client)
 	var lastErr error
+	var total time.Duration
+	var successes int64
 
-	for _, q := range tests {
+	for _, q := range testQuestions {
 		msg := new(dns.Msg)
 		msg.Id = dns.Id()
 		msg.RecursionDesired = true
 		msg.Question = make([]dns.Question, 1)
 		msg.Question[0] = q
 
-		resp, _, err := c.Exchange(msg, addr)
+		resp, t, err := c.Exchange(msg, addr)
 		if err != nil {
-			if err, ok := err.(net.Error); ok && err.Timeout() {
+			le := classifyError(err)
+			err = le
+			if le.Code == CodeTimeout {
 				// instant fail
-				return false, errors.New("TIMEOUT")
+				return 0, err
 			}
 			if lastErr != nil && err.Error() == lastErr.Error() {
-				return false, err
+				return 0, err
 			}
 			lastErr = err
 			continue
@@ -53,71 +79,176 @@ func (s *Server) Test() (ok bool, err error) {
 		if resp == nil {
 			err = errors.New("server did not return a result")
 			if lastErr != nil && err.Error() == lastErr.Error() {
-				return false, err
+				return 0, err
 			}
 			lastErr = err
 			continue
 		}
+
+		total += t
+		successes++
+	}
+
+	if successes > 0 {
+		rtt = total / time.Duration(successes)
+	}
+	return rtt, nil
+}
+
+// Diagnostic is the detailed outcome of one of the test queries made by Diagnose
+type Diagnostic struct {
+	Question string
+	RTT      time.Duration
+	Rcode    string   `json:",omitempty"`
+	Flags    string   `json:",omitempty"`
+	Answers  []string `json:",omitempty"`
+	Error    string   `json:",omitempty"`
+}
+
+// Diagnose makes each of the queries used by Test and reports the full outcome of each, for investigating why a
+// server is failing its tests.
+func (s *Server) Diagnose() (diagnostics []Diagnostic) {
+	addr := s.addr()
+	c := new(dns.Client)
+
+	for _, q := range testQuestions {
+		msg := new(dns.Msg)
+		msg.Id = dns.Id()
+		msg.RecursionDesired = true
+		msg.Question = []dns.Question{q}
+
+		d := Diagnostic{
+			Question: q.Name + " " + dns.TypeToString[q.Qtype],
+		}
+
+		resp, rtt, err := c.Exchange(msg, addr)
+		d.RTT = rtt
+		if err != nil {
+			d.Error = classifyError(err).Error()
+			if d.Error != err.Error() {
+				d.Error += " (" + err.Error() + ")"
+			}
+		} else {
+			d.Rcode = dns.RcodeToString[resp.Rcode]
+			d.Flags = headerFlags(resp)
+			for _, rr := range resp.Answer {
+				d.Answers = append(d.Answers, rr.String())
+			}
+		}
+
+		diagnostics = append(diagnostics, d)
+	}
+
+	return
+}
+
+// headerFlags lists the flags set in the header of a response in the same format as dig.
+func headerFlags(m *dns.Msg) string {
+	var flags []string
+	if m.Response {
+		flags = append(flags, "qr")
+	}
+	if m.Authoritative {
+		flags = append(flags, "aa")
+	}
+	if m.Truncated {
+		flags = append(flags, "tc")
 	}
+	if m.RecursionDesired {
+		flags = append(flags, "rd")
+	}
+	if m.RecursionAvailable {
+		flags = append(flags, "ra")
+	}
+	if m.AuthenticatedData {
+		flags = append(flags, "ad")
+	}
+	if m.CheckingDisabled {
+		flags = append(flags, "cd")
+	}
+	return strings.Join(flags, " ")
+}
 
-	return true, nil
+// Continent returns the two letter code of the continent the server is in, or an empty string if it is not known.
+func (s *Server) Continent() string {
+	return countryContinents[s.Country]
+}
+
+// HasTag checks if the server has been given a tag, ignoring case.
+func (s *Server) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
 }
 
 // Lookup makes a request for a given domain name and record type to the current server IP on the standard port 53.
 //
 // Results are returned as either a slice of strings representing the IPs returned, or an error object with a simplified
-// error response.
-func (s *Server) Lookup(name string, recordType uint16) (results []string, err error) {
-	addr := s.IP + ":53"
-	c := new(dns.Client)
+// error response. The round trip time is returned whenever the server responded, even if the response is an error.
+func (s *Server) Lookup(name string, recordType uint16) (results []string, rtt time.Duration, err error) {
+	resp, rtt, err := s.exchange(name, recordType)
+	if err != nil {
+		return nil, rtt, err
+	}
+
+	for _, rr := range resp.Answer {
+		results = append(results, strings.Split(rr.String(), "\t")[answerResult])
+	}
+
+	return
+}
 
+// exchange sends the question to the server and returns the full response for callers that need more than the
+// answer records. Error responses and empty answers are returned as an error alongside the response that caused them,
+// the response is only nil if the server did not respond.
+func (s *Server) exchange(name string, recordType uint16) (resp *dns.Msg, rtt time.Duration, err error) {
+	return s.send(newQuestion(name, recordType, true))
+}
+
+// newQuestion creates a message asking for a single record type, with or without recursion.
+func newQuestion(name string, recordType uint16, recurse bool) *dns.Msg {
 	msg := new(dns.Msg)
 	msg.Id = dns.Id()
-	msg.RecursionDesired = true
-	msg.Question = make([]dns.Question, 1)
-	msg.Question[0] = dns.Question{
+	msg.RecursionDesired = recurse
+	msg.Question = []dns.Question{{
 		Name:   dns.Fqdn(name),
 		Qtype:  recordType,
 		Qclass: dns.ClassINET,
+	}}
+	return msg
+}
+
+// send makes the request to the server, treating error responses and empty answers in the same way as exchange.
+func (s *Server) send(msg *dns.Msg) (resp *dns.Msg, rtt time.Duration, err error) {
+	c := new(dns.Client)
+	resp, rtt, err = c.Exchange(msg, s.addr())
+	if resp != nil && resp.Truncated && len(resp.Answer) == 0 {
+		return resp, rtt, ErrTruncated
 	}
 
-	resp, _, err := c.Exchange(msg, addr)
 	if err != nil {
-		if err, ok := err.(net.Error); ok && err.Timeout() {
-			return nil, errors.New("TIMEOUT")
-		}
-
-		switch t := err.(type) {
-		case *net.OpError:
-			if t.Op == "read" {
-				err = errors.New("CONNECTION REFUSED")
-			}
-
-		case syscall.Errno:
-			switch t {
-			case syscall.ECONNREFUSED:
-				err = errors.New("CONNECTION REFUSED")
-			}
-		}
-
-		return
+		return nil, 0, classifyError(err)
 	}
 
 	if resp.Rcode != dns.RcodeSuccess {
-		return nil, errors.New(dns.RcodeToString[resp.Rcode])
+		return resp, rtt, RcodeError(resp.Rcode)
 	}
 
 	if len(resp.Answer) == 0 {
-		return nil, errors.New("NOANSWER")
-	}
-
-	for _, rr := range resp.Answer {
-		results = append(results, strings.Split(rr.String(), "\t")[answerResult])
+		return resp, rtt, ErrNoAnswer
 	}
 
 	return
 }
 
+// addr is the address of the server on the standard port 53, which works for both IPv4 and IPv6 servers.
+func (s *Server) addr() string {
+	return net.JoinHostPort(s.IP, "53")
+}
+
 // Returns either the current server name or the IP address if a name is not available.
 func (s *Server) String() string {
 	if s.Name != "" {
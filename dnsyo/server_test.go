@@ -58,21 +58,22 @@ func TestServer_Lookup(t *testing.T) {
 		}
 
 		Convey("google.com NS as these are unlikely to change", func() {
-			results, err := s.Lookup("google.com", dns.TypeNS)
+			results, rtt, err := s.Lookup("google.com", dns.TypeNS)
 			So(err, ShouldBeNil)
+			So(rtt, ShouldBeGreaterThan, 0)
 			So(results, ShouldHaveLength, 4)
 			So(results, ShouldContain, "ns1.google.com.")
 		})
 
 		Convey("dne.itsg.host A does not exist, check the failure", func() {
-			results, err := s.Lookup("dne.itsg.host", dns.TypeA)
+			results, _, err := s.Lookup("dne.itsg.host", dns.TypeA)
 			So(results, ShouldBeNil)
 			So(err, ShouldBeError)
 			So(err.Error(), ShouldEqual, "NOANSWER")
 		})
 
 		Convey("itsg.test A cannot exist, check the failure is NXDOMAIN", func() {
-			results, err := s.Lookup("dne.itsg.test", dns.TypeA)
+			results, _, err := s.Lookup("dne.itsg.test", dns.TypeA)
 			So(results, ShouldBeNil)
 			So(err, ShouldBeError)
 			So(err.Error(), ShouldEqual, "NXDOMAIN")
@@ -87,7 +88,7 @@ func TestServer_Lookup(t *testing.T) {
 		}
 
 		Convey("itsg.host NS as these are unlikely to change", func() {
-			results, err := s.Lookup("itsg.host", dns.TypeNS)
+			results, _, err := s.Lookup("itsg.host", dns.TypeNS)
 			So(results, ShouldBeNil)
 			So(err, ShouldBeError)
 			So(err.Error(), ShouldEqual, "TIMEOUT")
@@ -102,10 +103,28 @@ func TestServer_Lookup(t *testing.T) {
 		}
 
 		Convey("google.com NS as these are unlikely to change", func() {
-			results, err := s.Lookup("google.com", dns.TypeNS)
+			results, _, err := s.Lookup("google.com", dns.TypeNS)
 			So(results, ShouldBeNil)
 			So(err, ShouldBeError)
 			So(err.Error(), ShouldEqual, "CONNECTION REFUSED")
 		})
 	})
 }
+
+func TestServer_Continent(t *testing.T) {
+	Convey("continent is found from the country", t, func() {
+		So((&Server{Country: "GB"}).Continent(), ShouldEqual, "EU")
+		So((&Server{Country: "US"}).Continent(), ShouldEqual, "NA")
+		So((&Server{}).Continent(), ShouldEqual, "")
+	})
+}
+
+func TestServer_HasTag(t *testing.T) {
+	s := Server{Tags: []string{"office", "ISP"}}
+
+	Convey("tags are matched ignoring case", t, func() {
+		So(s.HasTag("office"), ShouldBeTrue)
+		So(s.HasTag("isp"), ShouldBeTrue)
+		So(s.HasTag("home"), ShouldBeFalse)
+	})
+}
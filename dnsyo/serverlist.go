@@ -2,13 +2,11 @@ package dnsyo
 
 import (
 	"fmt"
-	"github.com/gocarina/gocsv"
 	log "github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v2"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"net"
-	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -55,14 +53,29 @@ type csvNameserver struct {
 // ServerList is an alias for a slice of Server objects used for performing bulk actions on multiple threads
 type ServerList []Server
 
-// ServersFromFile loads a ServerList from a YAML file. Will raise an error if the file cannot be opened or processed
+// ServersFromFile loads a ServerList from a YAML file. Will raise an error if the file cannot be opened or processed,
+// or a ValidationError listing the offending lines if any of the servers are invalid.
+//
+// Files written before the schema was versioned are migrated as they are read, and will be written in the current
+// version by the next call to DumpToFile.
 func ServersFromFile(filename string) (sl ServerList, err error) {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return
 	}
 
-	err = yaml.Unmarshal(data, &sl)
+	sl, version, err := parseResolverFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read resolver file %s: %s", filename, err)
+	}
+	if version < ResolverFileVersion {
+		log.WithFields(log.Fields{
+			"file":    filename,
+			"version": version,
+		}).Debug("Migrating resolver file from an older version")
+	}
+
+	err = sl.validate(filename, data)
 	if err != nil {
 		return nil, err
 	}
@@ -72,60 +85,45 @@ func ServersFromFile(filename string) (sl ServerList, err error) {
 
 // ServersFromCSVURL loads a ServerList from a CSV provided at a given URL.
 // Designed for use with public-info.dns
+//
+// The download is not cached, use a CSVFetcher to keep a local copy between runs.
 func ServersFromCSVURL(url string) (sl ServerList, err error) {
-	csvFile, err := http.Get(url)
-	if err != nil {
-		return
-	}
-
-	// sometimes the file can be truncated and have an incomplete final line.
-	// run a basic check to ensure it isn't going to error later.
-	bytes, err := ioutil.ReadAll(csvFile.Body)
-	if err != nil {
-		return
-	}
-
-	rows := strings.Split(string(bytes), "\n")
-	nCols := strings.Count(rows[0], ",")
-	if strings.Count(rows[len(rows)-1], ",") < nCols {
-		rows = rows[:len(rows)-2]
-	}
-	data := strings.Join(rows, "\n")
-
-	var servers []csvNameserver
-	err = gocsv.Unmarshal(strings.NewReader(data), &servers)
-	if err != nil {
-		return
-	}
+	return NewCSVFetcher(url, "").Servers()
+}
 
-	for _, ns := range servers {
+// ServersFromCSV loads a ServerList from a CSV in the public-dns.info format, row by row.
+// Servers below the reliability threshold and IPv6 servers are skipped.
+//
+// Sometimes the file can be truncated and have an incomplete final row, in which case the final row is discarded.
+func ServersFromCSV(r io.Reader) (sl ServerList, err error) {
+	err = readCSVNameservers(r, func(ns csvNameserver) {
 		if ip := net.ParseIP(ns.IPAddress); ip.To4() == nil {
-			continue // we can't process IPv6 yet
+			return // we can't process IPv6 yet
 		}
 		if ns.Reliability >= reliabilityThreshold {
 			s := Server{
 				IP:      ns.IPAddress,
 				Country: strings.ToUpper(ns.Country),
 				Name:    ns.Name,
+				DNSSEC:  ns.DNSSec,
 			}
 			sl = append(sl, s)
 		}
-	}
+	})
 
 	return
 }
 
 // DumpToFile a the current server list to a YAML file.
 // Includes a commented header to identify the fact it is generated.
+// The file is written to a temporary file first and moved into place so an interrupted write cannot corrupt it.
 func (sl *ServerList) DumpToFile(filename string) (err error) {
-	yml, err := yaml.Marshal(sl)
+	data, err := marshalResolverFile(*sl)
 	if err != nil {
 		return
 	}
 
-	data := append([]byte("#### GENERATED BY dnsyo update ####\n\n"), yml...)
-
-	err = ioutil.WriteFile(filename, data, 0744)
+	err = writeFileAtomic(filename, data, 0644)
 	return
 }
 
@@ -146,6 +144,7 @@ func (sl *ServerList) FilterCountry(country string) (fl ServerList, err error) {
 }
 
 // NRandom returns n random servers from the current server list in a new list.
+// Pinned servers are always selected before any others.
 // Will return an error if there are less than n servers in the current list.
 func (sl *ServerList) NRandom(n int) (rl ServerList, err error) {
 	ql := *sl
@@ -154,58 +153,136 @@ func (sl *ServerList) NRandom(n int) (rl ServerList, err error) {
 		return nil, fmt.Errorf("insufficient servers to populate list: %d of %d", len(ql), n)
 	}
 
-	rl = make(ServerList, n)
+	var pinned, others ServerList
+	for _, s := range ql {
+		if s.Pinned {
+			pinned = append(pinned, s)
+		} else {
+			others = append(others, s)
+		}
+	}
+
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	perm := r.Perm(n)
-	for i, randIndex := range perm[:n] {
-		rl[i] = ql[randIndex]
+	rl = make(ServerList, 0, len(ql))
+	for _, group := range []ServerList{pinned, others} {
+		for _, randIndex := range r.Perm(len(group)) {
+			rl = append(rl, group[randIndex])
+		}
 	}
 
+	return rl[:n], nil
+}
+
+// Filter returns a new list of the servers for which fn returns true.
+func (sl *ServerList) Filter(fn func(s Server) bool) (fl ServerList) {
+	for _, s := range *sl {
+		if fn(s) {
+			fl = append(fl, s)
+		}
+	}
 	return
 }
 
+// Enabled returns a new list without the servers that have been disabled.
+func (sl *ServerList) Enabled() ServerList {
+	return sl.Filter(func(s Server) bool {
+		return !s.Disabled
+	})
+}
+
+// Find returns the index of the server with the given IP address in the list, or -1 if it is not in the list.
+func (sl *ServerList) Find(ip string) int {
+	for i, s := range *sl {
+		if s.IP == ip {
+			return i
+		}
+	}
+	return -1
+}
+
+// MergeCuration copies the pins, tags, disabled flags and fingerprinted software from the servers in a previous list
+// onto the matching servers in the current list. Pinned servers that are missing from the current list are added back to it.
+func (sl *ServerList) MergeCuration(previous ServerList) {
+	for _, p := range previous {
+		i := sl.Find(p.IP)
+		if i < 0 {
+			if p.Pinned {
+				*sl = append(*sl, p)
+			}
+			continue
+		}
+
+		s := &(*sl)[i]
+		s.Pinned = p.Pinned
+		s.Disabled = p.Disabled
+		s.Tags = p.Tags
+		s.Software = p.Software
+	}
+}
+
 // ExecuteQuery runs a Query object in a specified number of threads.
 // The returned QueryResult is not associated with the provided Query, however may be set by the caller.
-func (sl *ServerList) ExecuteQuery(q *Query, threads int) (qr QueryResults) {
-	qr = make(QueryResults)
+func (sl *ServerList) ExecuteQuery(q *Query, threads int) QueryResults {
+	return sl.executeQuery(q, threads, newValidationProbes())
+}
 
-	var wg sync.WaitGroup
+// executeQuery runs a Query as ExecuteQuery does, sharing the DNSSEC validation probes with the rest of the run.
+func (sl *ServerList) executeQuery(q *Query, threads int, probes *validationProbes) (qr QueryResults) {
+	qr = make(QueryResults)
+	keys := sl.resultKeys()
 	var mtx sync.Mutex
 
-	queue := make(chan Server, len(*sl))
+	runParallel(len(*sl), threads, func(i int) {
+		r := q.result((*sl)[i], probes)
 
-	// start workers
-	for i := 0; i < threads; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			for s := range queue {
-				res, err := s.Lookup(q.Domain, q.Type)
-				ans := strings.Join(res, "\n")
+		mtx.Lock()
+		qr[keys[i]] = r
+		mtx.Unlock()
+	})
+	return
+}
 
-				r := new(Result)
+// resultKeys gives the key each server's results are stored under, which is its name or IP address. Servers that
+// share a name, such as the addresses of an anycast service, are keyed by their name and IP address instead.
+func (sl *ServerList) resultKeys() []string {
+	names := make(map[string]int)
+	for _, s := range *sl {
+		names[s.String()]++
+	}
 
-				if err != nil {
-					r.Error = err.Error()
-				} else {
-					r.Answer = ans
-				}
+	keys := make([]string, len(*sl))
+	for i, s := range *sl {
+		keys[i] = s.String()
+		if names[keys[i]] > 1 {
+			keys[i] = fmt.Sprintf("%s (%s)", s.Name, s.IP)
+		}
+	}
+	return keys
+}
 
-				mtx.Lock()
-				qr[s.String()] = r
-				mtx.Unlock()
-			}
+// runParallel calls work for each of n jobs, numbered from 0, in the given number of threads and returns once they
+// have all finished. Work is called concurrently, so it must lock anything it shares.
+func runParallel(n, threads int, work func(i int)) {
+	var wg sync.WaitGroup
+	queue := make(chan int, n)
 
-		}(i)
+	// start workers
+	for t := 0; t < threads; t++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				work(i)
+			}
+		}()
 	}
 
-	for _, s := range *sl {
-		queue <- s
+	for i := 0; i < n; i++ {
+		queue <- i
 	}
 	close(queue)
 
 	wg.Wait()
-	return
 }
 
 //func (sl *ServerList) StreamQuery(q *Query, threads int, results chan QueryResults) {
@@ -251,6 +328,36 @@ func (sl *ServerList) ExecuteQuery(q *Query, threads int) (qr QueryResults) {
 
 // TestAll tests all the servers in the current list and returns a new list with only the workings ones.
 func (sl *ServerList) TestAll(threads int) (working ServerList) {
+	working, _ = sl.TestAllWithFailures(threads)
+	return working
+}
+
+// TestAllWithFailures tests all the servers in the current list in the same way as TestAll, additionally returning
+// the servers that failed grouped by the reason they were disabled.
+func (sl *ServerList) TestAllWithFailures(threads int) (working ServerList, failures map[string]ServerList) {
+	failures = make(map[string]ServerList)
+
+	sl.testAll(threads, func(s Server, _ time.Duration, err error) {
+		if err == nil {
+			working = append(working, s)
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"server": s.String(),
+			"reason": err,
+		}).Info("Disabling server")
+
+		reason := err.Error()
+		failures[reason] = append(failures[reason], s)
+	})
+
+	return working, failures
+}
+
+// testAll runs Server.test against every server in the list on the given number of threads.
+// The callback is called once for each server with the result of the test, and is never called concurrently.
+func (sl *ServerList) testAll(threads int, callback func(s Server, rtt time.Duration, err error)) {
 	var wg sync.WaitGroup
 	var mutex sync.Mutex
 	testQueue := make(chan Server, len(*sl))
@@ -262,17 +369,11 @@ func (sl *ServerList) TestAll(threads int) (working ServerList) {
 			defer wg.Done()
 			for s := range testQueue {
 				log.WithField("thread", i).Debug("Testing " + s.Name)
-				if ok, err := s.Test(); ok {
-					mutex.Lock()
-					working = append(working, s)
-					mutex.Unlock()
-				} else {
-					log.WithFields(log.Fields{
-						"thread": i,
-						"server": s.String(),
-						"reason": err,
-					}).Info("Disabling server")
-				}
+				rtt, err := s.test()
+
+				mutex.Lock()
+				callback(s, rtt, err)
+				mutex.Unlock()
 			}
 		}(i)
 	}
@@ -284,5 +385,4 @@ func (sl *ServerList) TestAll(threads int) (working ServerList) {
 	close(testQueue)
 
 	wg.Wait()
-	return working
 }
@@ -4,6 +4,7 @@ import (
 	"github.com/miekg/dns"
 	. "github.com/smartystreets/goconvey/convey"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -60,8 +61,8 @@ func TestServersFromCSVURL(t *testing.T) {
 		sl, err := ServersFromCSVURL(testCsvURL)
 		So(err, ShouldBeNil)
 		So(len(sl), ShouldBeGreaterThan, testCsvMinCount)
-		So(sl, ShouldContain, dnswatch1)
-		So(sl, ShouldNotContain, badServer)
+		So(sl.Find(dnswatch1.IP), ShouldBeGreaterThanOrEqualTo, 0)
+		So(sl.Find(badServer.IP), ShouldEqual, -1)
 	})
 }
 
@@ -133,6 +134,108 @@ func TestServerList_NRandom(t *testing.T) {
 		_, err := sl.NRandom(10)
 		So(err, ShouldBeError)
 	})
+
+	Convey("servers are selected from the whole list", t, func() {
+		seen := make(map[string]bool)
+		for i := 0; i < 50; i++ {
+			rl, _ := sl.NRandom(1)
+			seen[rl[0].IP] = true
+		}
+		So(len(seen), ShouldBeGreaterThan, 1)
+	})
+
+	Convey("pinned servers are always selected", t, func() {
+		pl := append(ServerList{}, sl...)
+		pl[7].Pinned = true
+		pl[8].Pinned = true
+
+		for i := 0; i < 10; i++ {
+			rl, err := pl.NRandom(3)
+			So(err, ShouldBeNil)
+			So(rl, ShouldContain, pl[7])
+			So(rl, ShouldContain, pl[8])
+		}
+	})
+}
+
+func TestServerList_Filter(t *testing.T) {
+	sl := ServerList{
+		{IP: "127.0.0.1", Country: "GB"},
+		{IP: "127.0.0.2", Country: "US", Disabled: true},
+		{IP: "127.0.0.3", Country: "GB"},
+	}
+
+	Convey("filter keeps the matching servers", t, func() {
+		fl := sl.Filter(func(s Server) bool {
+			return s.Country == "GB"
+		})
+		So(fl, ShouldResemble, ServerList{sl[0], sl[2]})
+	})
+
+	Convey("enabled removes disabled servers", t, func() {
+		So(sl.Enabled(), ShouldResemble, ServerList{sl[0], sl[2]})
+	})
+
+	Convey("find gives the index of the server", t, func() {
+		So(sl.Find("127.0.0.3"), ShouldEqual, 2)
+		So(sl.Find("127.0.0.4"), ShouldEqual, -1)
+	})
+}
+
+func TestServerList_MergeCuration(t *testing.T) {
+	previous := ServerList{
+		{IP: "127.0.0.1", Pinned: true, Tags: []string{"office"}, Software: "Unbound 1.6.0"},
+		{IP: "127.0.0.2", Disabled: true},
+		{IP: "127.0.0.3", Pinned: true, Name: "manual"},
+		{IP: "127.0.0.4", Tags: []string{"gone"}},
+	}
+
+	Convey("curation is copied onto the new list", t, func() {
+		sl := ServerList{
+			{IP: "127.0.0.1", Name: "new"},
+			{IP: "127.0.0.2"},
+			{IP: "127.0.0.5"},
+		}
+		sl.MergeCuration(previous)
+
+		So(sl, ShouldResemble, ServerList{
+			{IP: "127.0.0.1", Name: "new", Pinned: true, Tags: []string{"office"}, Software: "Unbound 1.6.0"},
+			{IP: "127.0.0.2", Disabled: true},
+			{IP: "127.0.0.5"},
+			{IP: "127.0.0.3", Pinned: true, Name: "manual"},
+		})
+	})
+}
+
+func TestServerList_resultKeys(t *testing.T) {
+	Convey("servers are keyed by name, or IP address if they have none", t, func() {
+		sl := ServerList{{IP: "127.0.0.1", Name: "one"}, {IP: "127.0.0.2"}}
+		So(sl.resultKeys(), ShouldResemble, []string{"one", "127.0.0.2"})
+	})
+
+	Convey("servers that share a name are keyed by name and IP address", t, func() {
+		sl := ServerList{
+			{IP: "127.0.0.1", Name: "anycast"},
+			{IP: "127.0.0.2", Name: "anycast"},
+			{IP: "127.0.0.3", Name: "other"},
+		}
+		So(sl.resultKeys(), ShouldResemble, []string{"anycast (127.0.0.1)", "anycast (127.0.0.2)", "other"})
+	})
+}
+
+func TestRunParallel(t *testing.T) {
+	Convey("every job is run once", t, func() {
+		var mtx sync.Mutex
+		runs := make([]int, 50)
+		runParallel(len(runs), 7, func(i int) {
+			mtx.Lock()
+			runs[i]++
+			mtx.Unlock()
+		})
+		for _, n := range runs {
+			So(n, ShouldEqual, 1)
+		}
+	})
 }
 
 func TestServerList_Query(t *testing.T) {
@@ -153,7 +256,8 @@ func TestServerList_Query(t *testing.T) {
 		So(len(result), ShouldEqual, len(sl))
 
 		// check the result we have is correct
-		So(result[sl[8].String()], ShouldResemble, &Result{Error: "TIMEOUT"})
+		So(result[sl[8].String()], ShouldResemble, &Result{Error: "TIMEOUT", ErrorCode: CodeTimeout, Country: "GB"})
+		So(result[sl[0].String()].RTT, ShouldBeGreaterThan, 0)
 	})
 }
 
@@ -0,0 +1,104 @@
+package dnsyo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ServerStats counts the servers in a list by location and capabilities
+type ServerStats struct {
+	Total, Pinned, Disabled int
+	Countries               map[string]int
+	Continents              map[string]int
+	Software                map[string]int
+	DNSSEC                  map[string]int
+}
+
+// Stats counts the servers in the current list by country, continent, software and DNSSEC support.
+// Servers without a known value are counted as UNKNOWN.
+func (sl *ServerList) Stats() (st ServerStats) {
+	st.Countries = make(map[string]int)
+	st.Continents = make(map[string]int)
+	st.Software = make(map[string]int)
+	st.DNSSEC = make(map[string]int)
+
+	orUnknown := func(v string) string {
+		if v == "" {
+			return "UNKNOWN"
+		}
+		return v
+	}
+
+	for _, s := range *sl {
+		st.Total++
+		if s.Pinned {
+			st.Pinned++
+		}
+		if s.Disabled {
+			st.Disabled++
+		}
+
+		st.Countries[orUnknown(s.Country)]++
+		st.Continents[orUnknown(s.Continent())]++
+		st.Software[orUnknown(s.Software)]++
+		if s.DNSSEC {
+			st.DNSSEC["supported"]++
+		} else {
+			st.DNSSEC["unsupported"]++
+		}
+	}
+
+	return
+}
+
+// ToTextSummary prints a human readable output of the statistics for use in the CLI.
+// Each group is ordered by the number of servers, largest first.
+func (st ServerStats) ToTextSummary() (text string) {
+	text = fmt.Sprintf(`
+ - SERVERS
+There are %d servers in the list,
+%d are pinned and %d are disabled`, st.Total, st.Pinned, st.Disabled)
+	text += "\n\n"
+
+	groups := []struct {
+		name   string
+		counts map[string]int
+	}{
+		{"continent", st.Continents},
+		{"country", st.Countries},
+		{"software", st.Software},
+		{"DNSSEC", st.DNSSEC},
+	}
+
+	for _, g := range groups {
+		text += fmt.Sprintf("\nBy %s;\n", g.name)
+		for _, k := range sortedByCount(g.counts) {
+			text += fmt.Sprintf("%6d  %s\n", g.counts[k], k)
+		}
+	}
+
+	return text
+}
+
+// ToJSON prints a JSON representation of the statistics
+func (st ServerStats) ToJSON() (string, error) {
+	text, err := json.Marshal(st)
+	return string(text), err
+}
+
+// sortedByCount returns the keys of the map ordered by their count, largest first, then alphabetically.
+func sortedByCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -0,0 +1,42 @@
+package dnsyo
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestServerList_Stats(t *testing.T) {
+	sl := ServerList{
+		{IP: "127.0.0.1", Country: "GB", Software: "unbound 1.6.7", DNSSEC: true, Pinned: true},
+		{IP: "127.0.0.2", Country: "DE", Software: "unbound 1.6.7", DNSSEC: true},
+		{IP: "127.0.0.3", Country: "US", Disabled: true},
+		{IP: "127.0.0.4"},
+	}
+
+	st := sl.Stats()
+
+	Convey("servers are counted by each group", t, func() {
+		So(st.Total, ShouldEqual, 4)
+		So(st.Pinned, ShouldEqual, 1)
+		So(st.Disabled, ShouldEqual, 1)
+		So(st.Countries, ShouldResemble, map[string]int{"GB": 1, "DE": 1, "US": 1, "UNKNOWN": 1})
+		So(st.Continents, ShouldResemble, map[string]int{"EU": 2, "NA": 1, "UNKNOWN": 1})
+		So(st.Software, ShouldResemble, map[string]int{"unbound 1.6.7": 2, "UNKNOWN": 2})
+		So(st.DNSSEC, ShouldResemble, map[string]int{"supported": 2, "unsupported": 2})
+	})
+
+	Convey("text summary is ordered by count", t, func() {
+		text := st.ToTextSummary()
+		So(text, ShouldStartWith, `
+ - SERVERS
+There are 4 servers in the list,
+1 are pinned and 1 are disabled`)
+		So(text, ShouldContainSubstring, "\nBy continent;\n     2  EU\n     1  NA\n     1  UNKNOWN\n")
+	})
+
+	Convey("json", t, func() {
+		json, err := st.ToJSON()
+		So(err, ShouldBeNil)
+		So(json, ShouldContainSubstring, `"Continents":{"EU":2,"NA":1,"UNKNOWN":1}`)
+	})
+}
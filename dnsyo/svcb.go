@@ -0,0 +1,237 @@
+package dnsyo
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"sort"
+	"strings"
+)
+
+// SVCB and HTTPS record types from RFC 9460. The miekg/dns library does not know them, so their records are unpacked
+// as RFC 3597 unknown records and parsed here.
+const (
+	TypeSVCB  uint16 = 64
+	TypeHTTPS uint16 = 65
+)
+
+// extraTypes are record types that can be queried by name although they are not known to the miekg/dns library.
+var extraTypes = map[string]uint16{
+	"SVCB":  TypeSVCB,
+	"HTTPS": TypeHTTPS,
+}
+
+// svcParamKeys are the names of the SvcParamKeys in the order they are numbered
+var svcParamKeys = []string{"mandatory", "alpn", "no-default-alpn", "port", "ipv4hint", "ech", "ipv6hint"}
+
+// SVCBRecord is a parsed SVCB or HTTPS record.
+type SVCBRecord struct {
+	Priority uint16
+	Target   string
+
+	ALPN          []string `json:",omitempty"`
+	NoDefaultALPN bool     `json:",omitempty"`
+	Port          uint16   `json:",omitempty"`
+	IPv4Hint      []string `json:",omitempty"`
+	IPv6Hint      []string `json:",omitempty"`
+	ECH           string   `json:",omitempty"`
+	Mandatory     []string `json:",omitempty"`
+
+	// Other holds any other parameters in the form key65000=<hex>
+	Other []string `json:",omitempty"`
+}
+
+// isSVCB checks if a record type has the SVCB format.
+func isSVCB(t uint16) bool {
+	return t == TypeSVCB || t == TypeHTTPS
+}
+
+// svcParamKey gives the name of a SvcParamKey.
+func svcParamKey(key uint16) string {
+	if int(key) < len(svcParamKeys) {
+		return svcParamKeys[key]
+	}
+	return fmt.Sprintf("key%d", key)
+}
+
+// parseSVCB parses the rdata of an SVCB or HTTPS record that was unpacked as an unknown record.
+func parseSVCB(rr dns.RR) (*SVCBRecord, error) {
+	unknown, ok := rr.(*dns.RFC3597)
+	if !ok || !isSVCB(rr.Header().Rrtype) {
+		return nil, errors.New("not an SVCB record")
+	}
+	rdata, err := hex.DecodeString(unknown.Rdata)
+	if err != nil || len(rdata) < 3 {
+		return nil, errors.New("invalid SVCB record")
+	}
+
+	s := &SVCBRecord{Priority: binary.BigEndian.Uint16(rdata)}
+	target, off, err := dns.UnpackDomainName(rdata, 2)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SVCB target: %s", err)
+	}
+	s.Target = target
+
+	for off < len(rdata) {
+		if off+4 > len(rdata) {
+			return nil, errors.New("truncated SVCB parameter")
+		}
+		key := binary.BigEndian.Uint16(rdata[off:])
+		length := int(binary.BigEndian.Uint16(rdata[off+2:]))
+		off += 4
+		if off+length > len(rdata) {
+			return nil, fmt.Errorf("truncated SVCB parameter %s", svcParamKey(key))
+		}
+		if err := s.setParam(key, rdata[off:off+length]); err != nil {
+			return nil, err
+		}
+		off += length
+	}
+	return s, nil
+}
+
+// setParam sets the field for a SvcParam from its wire format value.
+func (s *SVCBRecord) setParam(key uint16, value []byte) error {
+	invalid := fmt.Errorf("invalid SVCB parameter %s", svcParamKey(key))
+
+	switch key {
+	case 0:
+		if len(value)%2 != 0 {
+			return invalid
+		}
+		for i := 0; i < len(value); i += 2 {
+			s.Mandatory = append(s.Mandatory, svcParamKey(binary.BigEndian.Uint16(value[i:])))
+		}
+
+	case 1:
+		for i := 0; i < len(value); {
+			l := int(value[i])
+			if l == 0 || i+1+l > len(value) {
+				return invalid
+			}
+			s.ALPN = append(s.ALPN, string(value[i+1:i+1+l]))
+			i += 1 + l
+		}
+
+	case 2:
+		s.NoDefaultALPN = true
+
+	case 3:
+		if len(value) != 2 {
+			return invalid
+		}
+		s.Port = binary.BigEndian.Uint16(value)
+
+	case 4, 6:
+		size := net.IPv4len
+		if key == 6 {
+			size = net.IPv6len
+		}
+		if len(value) == 0 || len(value)%size != 0 {
+			return invalid
+		}
+		for i := 0; i < len(value); i += size {
+			ip := net.IP(value[i : i+size]).String()
+			if key == 4 {
+				s.IPv4Hint = append(s.IPv4Hint, ip)
+			} else {
+				s.IPv6Hint = append(s.IPv6Hint, ip)
+			}
+		}
+
+	case 5:
+		s.ECH = base64.StdEncoding.EncodeToString(value)
+
+	default:
+		s.Other = append(s.Other, fmt.Sprintf("%s=%s", svcParamKey(key), hex.EncodeToString(value)))
+	}
+	return nil
+}
+
+// String gives the record in presentation format with its parameters in a fixed order and the address hints sorted,
+// so that servers returning the same record are grouped together.
+func (s *SVCBRecord) String() string {
+	params := []string{fmt.Sprintf("%d", s.Priority), s.Target}
+
+	if len(s.Mandatory) > 0 {
+		mandatory := append([]string(nil), s.Mandatory...)
+		sort.Strings(mandatory)
+		params = append(params, "mandatory="+strings.Join(mandatory, ","))
+	}
+	if len(s.ALPN) > 0 {
+		params = append(params, "alpn="+strings.Join(s.ALPN, ","))
+	}
+	if s.NoDefaultALPN {
+		params = append(params, "no-default-alpn")
+	}
+	if s.Port != 0 {
+		params = append(params, fmt.Sprintf("port=%d", s.Port))
+	}
+	for _, hint := range []struct {
+		name string
+		ips  []string
+	}{{"ipv4hint", s.IPv4Hint}, {"ipv6hint", s.IPv6Hint}} {
+		if len(hint.ips) > 0 {
+			ips := append([]string(nil), hint.ips...)
+			sort.Strings(ips)
+			params = append(params, hint.name+"="+strings.Join(ips, ","))
+		}
+	}
+	if s.ECH != "" {
+		params = append(params, "ech="+s.ECH)
+	}
+	params = append(params, s.Other...)
+
+	return strings.Join(params, " ")
+}
+
+// svcbTextSummary produces the SVCB section of Query.ToTextSummary for SVCB and HTTPS queries. It lists the servers
+// that gave no records when others did, which usually means they strip the type, and the servers that failed on it.
+func (q *Query) svcbTextSummary() (text string) {
+	if !isSVCB(q.Type) {
+		return ""
+	}
+
+	var answered int
+	var empty []string
+	failed := make(map[string][]string)
+	for name, r := range q.Results {
+		switch {
+		case r.Error == "":
+			answered++
+		case r.ErrorCode == CodeNoAnswer:
+			empty = append(empty, name)
+		case r.ErrorCode == CodeRcode || r.ErrorCode == CodeMalformed:
+			failed[r.Error] = append(failed[r.Error], name)
+		}
+	}
+
+	if answered == 0 || len(empty) == 0 && len(failed) == 0 {
+		return ""
+	}
+
+	text = fmt.Sprintf("\nAnd here is how they handle %s records;\n\n", q.GetType())
+	text += fmt.Sprintf("%d servers returned %s records\n\n", answered, q.GetType())
+
+	if len(empty) > 0 {
+		sort.Strings(empty)
+		text += fmt.Sprintf("%d servers returned no records and may be stripping them;\n%s\n\n",
+			len(empty), strings.Join(empty, ", "))
+	}
+
+	var errs []string
+	for err := range failed {
+		errs = append(errs, err)
+	}
+	sort.Strings(errs)
+	for _, err := range errs {
+		sort.Strings(failed[err])
+		text += fmt.Sprintf("%d servers failed with %s;\n%s\n\n", len(failed[err]), err, strings.Join(failed[err], ", "))
+	}
+
+	return text
+}
@@ -0,0 +1,87 @@
+package dnsyo
+
+import (
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+// httpsRecord is example.com HTTPS 1 . alpn=h3,h2 port=8443 ipv4hint=192.0.2.2,192.0.2.1 ech=AQI= in wire format
+const httpsRecord = "0001" + "00" +
+	"0001" + "0006" + "026833" + "026832" +
+	"0003" + "0002" + "20fb" +
+	"0004" + "0008" + "c0000202" + "c0000201" +
+	"0005" + "0002" + "0102"
+
+// unknownRR creates a record of a type unknown to the miekg/dns library from its wire format rdata.
+func unknownRR(rrtype uint16, rdata string) dns.RR {
+	return &dns.RFC3597{
+		Hdr:   dns.RR_Header{Name: "example.com.", Rrtype: rrtype, Class: dns.ClassINET, Ttl: 300},
+		Rdata: rdata,
+	}
+}
+
+func TestParseSVCB(t *testing.T) {
+	Convey("HTTPS records are parsed from their RFC 3597 form", t, func() {
+		s, err := parseSVCB(unknownRR(TypeHTTPS, httpsRecord))
+		So(err, ShouldBeNil)
+		So(s, ShouldResemble, &SVCBRecord{
+			Priority: 1,
+			Target:   ".",
+			ALPN:     []string{"h3", "h2"},
+			Port:     8443,
+			IPv4Hint: []string{"192.0.2.2", "192.0.2.1"},
+			ECH:      "AQI=",
+		})
+		So(s.String(), ShouldEqual, "1 . alpn=h3,h2 port=8443 ipv4hint=192.0.2.1,192.0.2.2 ech=AQI=")
+	})
+
+	Convey("truncated parameters are an error", t, func() {
+		_, err := parseSVCB(unknownRR(TypeSVCB, "0001"+"00"+"0003"+"0002"+"20"))
+		So(err, ShouldBeError, "truncated SVCB parameter port")
+	})
+
+	Convey("other records are not parsed", t, func() {
+		rr, _ := dns.NewRR("example.com. 300 IN A 192.0.2.1")
+		_, err := parseSVCB(rr)
+		So(err, ShouldBeError)
+	})
+}
+
+func TestQuery_SVCB(t *testing.T) {
+	Convey("HTTPS and SVCB can be queried by name", t, func() {
+		q := &Query{}
+		So(q.SetType("https"), ShouldBeNil)
+		So(q.Type, ShouldEqual, TypeHTTPS)
+		So(q.GetType(), ShouldEqual, "HTTPS")
+	})
+
+	Convey("results show the parsed records", t, func() {
+		resp := new(dns.Msg)
+		resp.SetQuestion("example.com.", TypeHTTPS)
+		resp.Answer = []dns.RR{unknownRR(TypeHTTPS, httpsRecord)}
+
+		r := newResult(Server{}, resp, 0, nil)
+		So(r.Answer, ShouldEqual, "1 . alpn=h3,h2 port=8443 ipv4hint=192.0.2.1,192.0.2.2 ech=AQI=")
+		So(r.SVCB, ShouldHaveLength, 1)
+	})
+
+	Convey("the summary lists servers that strip or fail on the type", t, func() {
+		q := &Query{
+			Type: TypeHTTPS,
+			Results: QueryResults{
+				"a": &Result{Answer: "1 . alpn=h2"},
+				"b": &Result{Error: "NOANSWER", ErrorCode: CodeNoAnswer},
+				"c": &Result{Error: "FORMERR", ErrorCode: CodeRcode, Rcode: dns.RcodeFormatError},
+				"d": &Result{Error: "TIMEOUT", ErrorCode: CodeTimeout},
+			},
+		}
+		So(q.ToTextSummary(), ShouldContainSubstring, "\nAnd here is how they handle HTTPS records;\n\n"+
+			"1 servers returned HTTPS records\n\n"+
+			"1 servers returned no records and may be stripping them;\nb\n\n"+
+			"1 servers failed with FORMERR;\nc\n\n")
+
+		q.Type = dns.TypeA
+		So(q.svcbTextSummary(), ShouldBeEmpty)
+	})
+}
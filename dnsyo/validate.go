@@ -0,0 +1,376 @@
+package dnsyo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Security statuses given to results by a Validator
+const (
+	SecuritySecure   = "secure"
+	SecurityInsecure = "insecure"
+	SecurityBogus    = "bogus"
+)
+
+// rootAnchors are the DS records of the root zone's key signing keys, KSK-2017 and KSK-2024.
+var rootAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+// DefaultValidationResolver is the resolver used to fetch the DS and DNSKEY records for validation.
+const DefaultValidationResolver = "1.1.1.1"
+
+// Validator checks the signatures on the answers given by servers against a chain of trust from the trust anchors.
+//
+// The DS and DNSKEY records for each zone are fetched once through a single resolver and cached, so that the same
+// Validator can be used for all of the results of a query. The signatures on denials of existence are verified, but
+// the NSEC and NSEC3 records are not checked to prove the denial, and unsigned delegations are trusted without a proof.
+type Validator struct {
+	Anchors []*dns.DS
+
+	// lookup fetches records with the DO bit set, through the resolver unless replaced in tests
+	lookup func(name string, recordType uint16) (*dns.Msg, error)
+
+	mtx   sync.Mutex
+	zones map[string]*zoneKeys
+	cuts  map[string]string
+}
+
+// zoneKeys are the validated keys for a zone, fetched once.
+type zoneKeys struct {
+	once   sync.Once
+	keys   []*dns.DNSKEY
+	secure bool
+	err    error
+}
+
+// NewValidator creates a Validator for the root trust anchors that fetches records through the resolver.
+func NewValidator(resolver string) *Validator {
+	v := &Validator{
+		zones: make(map[string]*zoneKeys),
+		cuts:  make(map[string]string),
+	}
+
+	for _, a := range rootAnchors {
+		rr, err := dns.NewRR(a)
+		if err != nil {
+			panic(err)
+		}
+		v.Anchors = append(v.Anchors, rr.(*dns.DS))
+	}
+
+	s := Server{IP: resolver}
+	v.lookup = func(name string, recordType uint16) (*dns.Msg, error) {
+		resp, _, err := s.send(dnssecQuestion(name, recordType, false))
+		if resp != nil {
+			return resp, nil
+		}
+		return nil, err
+	}
+
+	return v
+}
+
+// LoadTrustAnchors replaces the trust anchors with the DS or DNSKEY records for the root zone in a file, one per line
+// in zone file format.
+func (v *Validator) LoadTrustAnchors(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var anchors []*dns.DS
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, ";") {
+			continue
+		}
+
+		rr, err := dns.NewRR(text)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", filename, line, err)
+		}
+		switch a := rr.(type) {
+		case *dns.DS:
+			anchors = append(anchors, a)
+		case *dns.DNSKEY:
+			anchors = append(anchors, a.ToDS(dns.SHA256))
+		default:
+			return fmt.Errorf("%s:%d: trust anchors must be DS or DNSKEY records", filename, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(anchors) == 0 {
+		return fmt.Errorf("no trust anchors found in %s", filename)
+	}
+	v.Anchors = anchors
+	return nil
+}
+
+// Validate checks the signatures on each RRset in the answer of a response, or in the authority section of a
+// negative response, and returns the least secure status of them. An empty string is returned if the status could
+// not be determined.
+func (v *Validator) Validate(resp *dns.Msg) string {
+	section := resp.Answer
+	if resp.Rcode != dns.RcodeSuccess || len(section) == 0 {
+		section = resp.Ns
+	}
+
+	var sigs []*dns.RRSIG
+	var order []string
+	rrsets := make(map[string][]dns.RR)
+	for _, rr := range section {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			sigs = append(sigs, sig)
+			continue
+		}
+		key := strings.ToLower(rr.Header().Name) + " " + dns.TypeToString[rr.Header().Rrtype]
+		if rrsets[key] == nil {
+			order = append(order, key)
+		}
+		rrsets[key] = append(rrsets[key], rr)
+	}
+
+	if len(order) == 0 {
+		return ""
+	}
+
+	status := SecuritySecure
+	for _, key := range order {
+		switch v.validateRRset(rrsets[key], sigs) {
+		case SecurityBogus:
+			return SecurityBogus
+		case SecurityInsecure:
+			status = SecurityInsecure
+		case "":
+			if status == SecuritySecure {
+				status = ""
+			}
+		}
+	}
+	return status
+}
+
+// validateRRset checks an RRset against the signatures covering it, using the keys of the zone that signed it.
+// If there are no signatures the RRset is only insecure if the zone it belongs to is not signed.
+func (v *Validator) validateRRset(rrset []dns.RR, sigs []*dns.RRSIG) string {
+	h := rrset[0].Header()
+
+	var covering []*dns.RRSIG
+	for _, sig := range sigs {
+		if sig.TypeCovered == h.Rrtype && strings.EqualFold(sig.Hdr.Name, h.Name) {
+			covering = append(covering, sig)
+		}
+	}
+
+	if len(covering) == 0 {
+		zone := v.zoneCut(h.Name, h.Rrtype)
+		if zone == "" {
+			return ""
+		}
+		if zk := v.zone(zone); zk.err == nil && !zk.secure {
+			return SecurityInsecure
+		}
+		return SecurityBogus
+	}
+
+	signer := covering[0].SignerName
+	if !dns.IsSubDomain(signer, h.Name) {
+		return SecurityBogus
+	}
+
+	zk := v.zone(signer)
+	switch {
+	case zk.err != nil:
+		return SecurityBogus
+	case !zk.secure:
+		return SecurityInsecure
+	case verifyRRset(rrset, covering, zk.keys) != nil:
+		return SecurityBogus
+	}
+	return SecuritySecure
+}
+
+// zoneCut finds the zone that a name belongs to, by the owner of the SOA record when asking for it. The SOA record
+// itself belongs to the zone at its owner name.
+func (v *Validator) zoneCut(name string, recordType uint16) string {
+	name = strings.ToLower(dns.Fqdn(name))
+	if recordType == dns.TypeSOA {
+		return name
+	}
+
+	v.mtx.Lock()
+	zone, ok := v.cuts[name]
+	v.mtx.Unlock()
+	if ok {
+		return zone
+	}
+
+	if resp, err := v.lookup(name, dns.TypeSOA); err == nil {
+		zone = strings.ToLower(soaOwner(resp))
+	}
+
+	v.mtx.Lock()
+	v.cuts[name] = zone
+	v.mtx.Unlock()
+	return zone
+}
+
+// zone returns the validated keys for a zone, fetching them the first time they are needed.
+func (v *Validator) zone(name string) *zoneKeys {
+	name = strings.ToLower(dns.Fqdn(name))
+
+	v.mtx.Lock()
+	zk, ok := v.zones[name]
+	if !ok {
+		zk = &zoneKeys{}
+		v.zones[name] = zk
+	}
+	v.mtx.Unlock()
+
+	zk.once.Do(func() {
+		zk.keys, zk.secure, zk.err = v.fetchKeys(name)
+	})
+	return zk
+}
+
+// fetchKeys follows the chain of trust to a zone. The DS records are validated with the parent zone's keys, or are
+// the trust anchors for the root, and the zone's DNSKEY RRset must be signed by a key matching one of them.
+// A zone is insecure if its parent is insecure or has no DS records for it.
+func (v *Validator) fetchKeys(zone string) (keys []*dns.DNSKEY, secure bool, err error) {
+	ds := v.Anchors
+	if zone != "." {
+		resp, err := v.lookup(zone, dns.TypeDS)
+		if err != nil {
+			return nil, false, err
+		}
+
+		rrset, sigs := splitSignatures(resp.Answer, dns.TypeDS)
+		if len(rrset) == 0 {
+			return nil, false, nil
+		}
+		if len(sigs) == 0 {
+			return nil, false, fmt.Errorf("DS records for %s are not signed", zone)
+		}
+
+		parent := strings.ToLower(sigs[0].SignerName)
+		if parent == zone || !dns.IsSubDomain(parent, zone) {
+			return nil, false, fmt.Errorf("DS records for %s are signed by %s", zone, parent)
+		}
+
+		pk := v.zone(parent)
+		if pk.err != nil || !pk.secure {
+			return nil, false, pk.err
+		}
+		if err := verifyRRset(rrset, sigs, pk.keys); err != nil {
+			return nil, false, fmt.Errorf("DS records for %s: %s", zone, err)
+		}
+
+		ds = nil
+		for _, rr := range rrset {
+			ds = append(ds, rr.(*dns.DS))
+		}
+	}
+
+	resp, err := v.lookup(zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rrset, sigs := splitSignatures(resp.Answer, dns.TypeDNSKEY)
+	var trusted []*dns.DNSKEY
+	for _, rr := range rrset {
+		k := rr.(*dns.DNSKEY)
+		keys = append(keys, k)
+		for _, d := range ds {
+			if k.KeyTag() == d.KeyTag && k.Algorithm == d.Algorithm &&
+				strings.EqualFold(k.ToDS(d.DigestType).Digest, d.Digest) {
+				trusted = append(trusted, k)
+			}
+		}
+	}
+
+	if len(trusted) == 0 {
+		return nil, false, fmt.Errorf("no DNSKEY for %s matches its DS records", zone)
+	}
+	if err := verifyRRset(rrset, sigs, trusted); err != nil {
+		return nil, false, fmt.Errorf("DNSKEY records for %s: %s", zone, err)
+	}
+
+	return keys, true, nil
+}
+
+// splitSignatures separates the records of a type from the signatures covering them.
+func splitSignatures(section []dns.RR, recordType uint16) (rrset []dns.RR, sigs []*dns.RRSIG) {
+	for _, rr := range section {
+		switch t := rr.(type) {
+		case *dns.RRSIG:
+			if t.TypeCovered == recordType {
+				sigs = append(sigs, t)
+			}
+		default:
+			if rr.Header().Rrtype == recordType {
+				rrset = append(rrset, rr)
+			}
+		}
+	}
+	return
+}
+
+// verifyRRset checks that at least one of the signatures over the RRset is current and was made by one of the keys.
+func verifyRRset(rrset []dns.RR, sigs []*dns.RRSIG, keys []*dns.DNSKEY) error {
+	now := time.Now()
+	err := errors.New("no signature from a trusted key")
+
+	for _, sig := range sigs {
+		for _, k := range keys {
+			if k.KeyTag() != sig.KeyTag || k.Algorithm != sig.Algorithm {
+				continue
+			}
+			if !sig.ValidityPeriod(now) {
+				err = errors.New("signature has expired or is not yet valid")
+				continue
+			}
+			if verr := sig.Verify(k, rrset); verr != nil {
+				err = verr
+				continue
+			}
+			return nil
+		}
+	}
+	return err
+}
+
+// securityTextSummary produces the local validation section of Query.ToTextSummary, or an empty string if the
+// answers were not validated.
+func (qr QueryResults) securityTextSummary() (text string) {
+	security := make(map[string]int)
+	for _, r := range qr {
+		if r.Security != "" {
+			security[r.Security]++
+		}
+	}
+
+	if len(security) == 0 {
+		return ""
+	}
+
+	text = fmt.Sprint("\nAnd here is what I found validating their answers;\n\n")
+	text += fmt.Sprintf("%d servers gave secure answers\n", security[SecuritySecure])
+	text += fmt.Sprintf("%d servers gave insecure answers\n", security[SecurityInsecure])
+	text += fmt.Sprintf("%d servers gave bogus answers\n", security[SecurityBogus])
+
+	return text
+}
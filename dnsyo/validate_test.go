@@ -0,0 +1,195 @@
+package dnsyo
+
+import (
+	"crypto"
+	"github.com/miekg/dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// testZone is a zone with a generated key for signing records in validation tests
+type testZone struct {
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newTestZone(name string) *testZone {
+	k := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := k.Generate(256)
+	if err != nil {
+		panic(err)
+	}
+	return &testZone{k, priv.(crypto.Signer)}
+}
+
+func (z *testZone) sign(rrset ...dns.RR) []dns.RR {
+	now := time.Now()
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrset[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+		Inception:  uint32(now.Add(-time.Hour).Unix()),
+		Expiration: uint32(now.Add(time.Hour).Unix()),
+		KeyTag:     z.key.KeyTag(),
+		SignerName: z.key.Hdr.Name,
+		Algorithm:  z.key.Algorithm,
+	}
+	if err := sig.Sign(z.priv, rrset); err != nil {
+		panic(err)
+	}
+	return append(rrset, sig)
+}
+
+func (z *testZone) ds() *dns.DS {
+	return z.key.ToDS(dns.SHA256)
+}
+
+func mustRR(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+// newTestValidator creates a validator for a signed root, test. and example.test. with an unsigned delegation to
+// insecure.test. and a delegation to broken.test. whose DS does not match its key.
+func newTestValidator() (v *Validator, example *testZone) {
+	root := newTestZone(".")
+	test := newTestZone("test.")
+	example = newTestZone("example.test.")
+	broken := newTestZone("broken.test.")
+	wrong := newTestZone("broken.test.")
+
+	answers := map[string][]dns.RR{
+		". DNSKEY":             root.sign(root.key),
+		"test. DS":             root.sign(test.ds()),
+		"test. DNSKEY":         test.sign(test.key),
+		"example.test. DS":     test.sign(example.ds()),
+		"example.test. DNSKEY": example.sign(example.key),
+		"broken.test. DS":      test.sign(wrong.ds()),
+		"broken.test. DNSKEY":  broken.sign(broken.key),
+	}
+	authority := map[string][]dns.RR{
+		"www.example.test. SOA":  {mustRR("example.test. 3600 IN SOA ns.example.test. hostmaster.example.test. 1 2 3 4 5")},
+		"www.insecure.test. SOA": {mustRR("insecure.test. 3600 IN SOA ns.insecure.test. hostmaster.insecure.test. 1 2 3 4 5")},
+	}
+
+	v = NewValidator("")
+	v.Anchors = []*dns.DS{root.ds()}
+	v.lookup = func(name string, recordType uint16) (*dns.Msg, error) {
+		key := name + " " + dns.TypeToString[recordType]
+		m := new(dns.Msg)
+		m.SetQuestion(name, recordType)
+		m.Answer = answers[key]
+		m.Ns = authority[key]
+		return m, nil
+	}
+
+	return v, example
+}
+
+func TestValidator_Validate(t *testing.T) {
+	v, example := newTestValidator()
+	a := mustRR("www.example.test. 300 IN A 192.0.2.1")
+
+	answer := func(rrs ...dns.RR) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion("www.example.test.", dns.TypeA)
+		m.Answer = rrs
+		return m
+	}
+
+	Convey("a signed answer with a chain to the trust anchor is secure", t, func() {
+		So(v.Validate(answer(example.sign(a)...)), ShouldEqual, SecuritySecure)
+	})
+
+	Convey("an answer that does not match its signature is bogus", t, func() {
+		signed := example.sign(a)
+		signed[0] = mustRR("www.example.test. 300 IN A 192.0.2.2")
+		So(v.Validate(answer(signed...)), ShouldEqual, SecurityBogus)
+	})
+
+	Convey("an unsigned answer from a signed zone is bogus", t, func() {
+		So(v.Validate(answer(a)), ShouldEqual, SecurityBogus)
+	})
+
+	Convey("an unsigned answer from an unsigned zone is insecure", t, func() {
+		m := answer(mustRR("www.insecure.test. 300 IN A 192.0.2.1"))
+		So(v.Validate(m), ShouldEqual, SecurityInsecure)
+	})
+
+	Convey("an answer from a zone whose key does not match its DS is bogus", t, func() {
+		broken := newTestZone("broken.test.")
+		m := answer(broken.sign(mustRR("www.broken.test. 300 IN A 192.0.2.1"))...)
+		So(v.Validate(m), ShouldEqual, SecurityBogus)
+		So(v.zone("broken.test.").err, ShouldBeError, "no DNSKEY for broken.test. matches its DS records")
+	})
+
+	Convey("signed denials are validated from the authority section", t, func() {
+		m := answer()
+		m.Rcode = dns.RcodeNameError
+		m.Ns = example.sign(mustRR("example.test. 3600 IN SOA ns.example.test. hostmaster.example.test. 1 2 3 4 5"))
+		So(v.Validate(m), ShouldEqual, SecuritySecure)
+	})
+
+	Convey("an empty response has no status", t, func() {
+		So(v.Validate(answer()), ShouldBeEmpty)
+	})
+}
+
+func TestValidator_LoadTrustAnchors(t *testing.T) {
+	v := NewValidator("")
+	f, _ := ioutil.TempFile("", "anchors")
+	defer os.Remove(f.Name())
+
+	Convey("the root anchors are used by default", t, func() {
+		So(v.Anchors, ShouldHaveLength, 2)
+		So(v.Anchors[0].KeyTag, ShouldEqual, 20326)
+	})
+
+	Convey("DS and DNSKEY records are loaded", t, func() {
+		z := newTestZone(".")
+		ioutil.WriteFile(f.Name(), []byte("; test anchors\n\n"+rootAnchors[0]+"\n"+z.key.String()+"\n"), 0644)
+
+		So(v.LoadTrustAnchors(f.Name()), ShouldBeNil)
+		So(v.Anchors, ShouldHaveLength, 2)
+		So(v.Anchors[1].KeyTag, ShouldEqual, z.key.KeyTag())
+	})
+
+	Convey("other records are rejected", t, func() {
+		ioutil.WriteFile(f.Name(), []byte("example.test. 300 IN A 192.0.2.1\n"), 0644)
+		So(v.LoadTrustAnchors(f.Name()), ShouldBeError)
+	})
+
+	Convey("an empty file is rejected", t, func() {
+		ioutil.WriteFile(f.Name(), nil, 0644)
+		So(v.LoadTrustAnchors(f.Name()), ShouldBeError)
+	})
+}
+
+func TestQuery_BogusSummary(t *testing.T) {
+	q := &Query{
+		Domain: "www.example.test",
+		Type:   dns.TypeA,
+		Results: QueryResults{
+			"a": &Result{Answer: "192.0.2.1", Security: SecuritySecure},
+			"b": &Result{Answer: "192.0.2.1", Security: SecuritySecure},
+			"c": &Result{Answer: "192.0.2.1", Security: SecurityBogus},
+		},
+	}
+
+	Convey("bogus answers are grouped separately", t, func() {
+		text := q.ToTextSummary()
+		So(text, ShouldContainSubstring, "2 servers responded with;\n192.0.2.1\n\n")
+		So(text, ShouldContainSubstring, "\nAnd here are the answers that failed DNSSEC validation;\n\n1 servers responded with;\n192.0.2.1\n\n")
+		So(text, ShouldContainSubstring, "\nAnd here is what I found validating their answers;\n\n"+
+			"2 servers gave secure answers\n0 servers gave insecure answers\n1 servers gave bogus answers\n")
+	})
+}